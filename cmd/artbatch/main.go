@@ -0,0 +1,343 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Command artbatch draws many pictures in parallel and ranks them.
+//
+// It runs every combination of seed, strategy and rating, scores each picture
+// with several ratings, and writes a sortable HTML gallery and a CSV of all
+// scores.
+//
+// Each job's strategy has its own random source, seeded by the job's seed,
+// so a seed draws the same picture however many workers there are.
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"html/template"
+	"image"
+	"image/draw"
+	"image/png"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/artist"
+	"github.com/tswast/pixelsketches/village/debugview"
+	"github.com/tswast/pixelsketches/village/gui"
+	"github.com/tswast/pixelsketches/village/perception"
+	"github.com/tswast/pixelsketches/village/strategy"
+)
+
+//go:embed gallery.html
+var galleryHTML string
+
+var gallery = template.Must(template.New("gallery").Parse(galleryHTML))
+
+// Struct job is one picture to draw.
+type job struct {
+	Seed     int64
+	Strategy string
+	Rating   string
+}
+
+// Dir is the directory of the job's output, relative to -out-dir.
+func (j job) Dir() string {
+	return fmt.Sprintf("%s-%s-%d", j.Strategy, j.Rating, j.Seed)
+}
+
+// Struct result is a drawn picture and its scores.
+type result struct {
+	job
+	Frames    int
+	Seconds   float64
+	Scores    []float64
+	TimeLapse bool
+	Err       error
+}
+
+// usesRating returns true if a strategy draws differently for each rating.
+func usesRating(st string) bool {
+	return st == "ideal"
+}
+
+// newStrategy creates a strategy with its own random source, since jobs run
+// at the same time.
+func newStrategy(st string, rating perception.Rating, seed int64) (strategy.Strategizer, error) {
+	rng := rand.New(rand.NewSource(seed))
+	switch st {
+	case "random":
+		return &strategy.RandomWalk{Rand: rng}, nil
+	case "ideal":
+		return &strategy.Ideal{Rating: rating, Rand: rng}, nil
+	case "plurality":
+		// Voters run at the same time, so each needs its own source too.
+		var voters []*strategy.Ideal
+		for i, ideal := range perception.DefaultInterests {
+			voters = append(voters, &strategy.Ideal{
+				Rating: perception.NewRating(ideal, palettes.PICO8[i]),
+				Rand:   rand.New(rand.NewSource(rng.Int63())),
+			})
+		}
+		return &strategy.Plurality{Voters: voters, Rand: rng}, nil
+	}
+	return nil, fmt.Errorf("Unexpected value for strategy: %q", st)
+}
+
+func writeGIF(p string, g *debugview.GIF) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %s", p, err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := g.Encode(w); err != nil {
+		return fmt.Errorf("Error encoding %s: %s", p, err)
+	}
+	return w.Flush()
+}
+
+func readImage(p string) (image.Image, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening %s: %s", p, err)
+	}
+	defer f.Close()
+	im, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding %s: %s", p, err)
+	}
+	return im, nil
+}
+
+// Struct batch is the configuration shared by all jobs.
+type batch struct {
+	dir        string
+	maxIter    int
+	timeLapse  int
+	scoreNames []string
+}
+
+// run draws a picture and scores it.
+func (b *batch) run(j job) result {
+	res := result{job: j}
+	s, err := newStrategy(j.Strategy, perception.Ratings[j.Rating], j.Seed)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	dir := filepath.Join(b.dir, j.Dir())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		res.Err = fmt.Errorf("Error creating %s: %s", dir, err)
+		return res
+	}
+
+	var anim *debugview.GIF
+	if b.timeLapse > 0 {
+		anim = &debugview.GIF{Delay: 2}
+	}
+	opts := artist.Options{
+		OutPath:      filepath.Join(dir, "out.png"),
+		Seed:         j.Seed,
+		NoGlobalSeed: true,
+		MaxIter:      b.maxIter,
+		OnFrame: func(fr artist.Frame, app *gui.AppState) {
+			res.Frames = fr.Frame + 1
+			if anim != nil && fr.Frame%b.timeLapse == 0 {
+				anim.Add(gui.CopyAppState(app).Image)
+			}
+		},
+	}
+	start := time.Now()
+	if err := artist.Main(opts, s); err != nil {
+		res.Err = err
+		return res
+	}
+	res.Seconds = time.Since(start).Seconds()
+
+	im, err := readImage(opts.OutPath)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if anim != nil {
+		pim := image.NewPaletted(im.Bounds(), palettes.PICO8)
+		draw.Draw(pim, pim.Bounds(), im, image.ZP, draw.Src)
+		anim.Add(pim)
+		if err := writeGIF(filepath.Join(dir, "timelapse.gif"), anim); err != nil {
+			res.Err = err
+			return res
+		}
+		res.TimeLapse = true
+	}
+	for _, n := range b.scoreNames {
+		res.Scores = append(res.Scores, perception.Ratings[n](im))
+	}
+	return res
+}
+
+// runAll draws every job with a pool of workers.
+func (b *batch) runAll(jobs []job, workers int) []result {
+	results := make([]result, len(jobs))
+	jobc := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobc {
+				results[i] = b.run(jobs[i])
+				if results[i].Err != nil {
+					log.Printf("%s: %s\n", jobs[i].Dir(), results[i].Err)
+				} else {
+					log.Printf("%s: done in %d frames\n", jobs[i].Dir(), results[i].Frames)
+				}
+			}
+		}()
+	}
+	for i := range jobs {
+		jobc <- i
+	}
+	close(jobc)
+	wg.Wait()
+	return results
+}
+
+func (b *batch) writeCSV(p string, results []result) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %s", p, err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write(append([]string{"seed", "strategy", "rating", "frames", "seconds"}, append(b.scoreNames, "path")...))
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		row := []string{
+			strconv.FormatInt(r.Seed, 10),
+			r.Strategy,
+			r.Rating,
+			strconv.Itoa(r.Frames),
+			strconv.FormatFloat(r.Seconds, 'f', 3, 64),
+		}
+		for _, s := range r.Scores {
+			row = append(row, strconv.FormatFloat(s, 'f', -1, 64))
+		}
+		row = append(row, filepath.ToSlash(filepath.Join(r.Dir(), "out.png")))
+		w.Write(row)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (b *batch) writeGallery(p string, results []result) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %s", p, err)
+	}
+	defer f.Close()
+	var ok []result
+	for _, r := range results {
+		if r.Err == nil {
+			ok = append(ok, r)
+		}
+	}
+	w := bufio.NewWriter(f)
+	err = gallery.Execute(w, struct {
+		ScoreNames []string
+		Results    []result
+	}{b.scoreNames, ok})
+	if err != nil {
+		return fmt.Errorf("Error writing %s: %s", p, err)
+	}
+	return w.Flush()
+}
+
+func splitNames(s string) []string {
+	var out []string
+	for _, n := range strings.Split(s, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func main() {
+	var seeds int
+	var seed int
+	var sts string
+	var rns string
+	var sns string
+	var workers int
+	b := &batch{}
+	flag.IntVar(&seeds, "seeds", 4, "Number of seeds to draw with.")
+	flag.IntVar(&seed, "seed", 19700101, "First seed.")
+	flag.StringVar(&sts, "strategies", "random,ideal,plurality", "Comma-separated strategies: random|ideal|plurality")
+	flag.StringVar(&rns, "ratings", "whole", "Comma-separated names of ratings for the ideal strategy to maximize.")
+	flag.StringVar(&sns, "score", "", "Comma-separated names of ratings to score pictures with. Default is all.")
+	flag.IntVar(&workers, "workers", runtime.NumCPU(), "Number of pictures to draw at once.")
+	flag.IntVar(&b.maxIter, "max-iter", 10000, "Maximum number of iterations for each picture.")
+	flag.IntVar(&b.timeLapse, "timelapse", 10, "Write a timelapse GIF with every nth frame. Zero means no timelapse.")
+	flag.StringVar(&b.dir, "out-dir", "batch", "Directory to write pictures, index.html and scores.csv to.")
+	flag.Parse()
+
+	ratings := splitNames(rns)
+	b.scoreNames = splitNames(sns)
+	if len(b.scoreNames) == 0 {
+		for n := range perception.Ratings {
+			b.scoreNames = append(b.scoreNames, n)
+		}
+		sort.Strings(b.scoreNames)
+	}
+	for _, n := range append(ratings, b.scoreNames...) {
+		if _, ok := perception.Ratings[n]; !ok {
+			log.Fatalf("Unexpected value for rating: %q", n)
+		}
+	}
+
+	var jobs []job
+	for _, st := range splitNames(sts) {
+		if _, err := newStrategy(st, nil, 0); err != nil {
+			log.Fatal(err)
+		}
+		rs := ratings
+		if !usesRating(st) {
+			rs = []string{"none"}
+		}
+		for _, rn := range rs {
+			for i := 0; i < seeds; i++ {
+				jobs = append(jobs, job{Seed: int64(seed + i), Strategy: st, Rating: rn})
+			}
+		}
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		log.Fatalf("Error creating %s: %s", b.dir, err)
+	}
+
+	results := b.runAll(jobs, workers)
+	if err := b.writeCSV(filepath.Join(b.dir, "scores.csv"), results); err != nil {
+		log.Fatal(err)
+	}
+	if err := b.writeGallery(filepath.Join(b.dir, "index.html"), results); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("wrote %d pictures to %s\n", len(jobs), b.dir)
+}
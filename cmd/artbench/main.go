@@ -0,0 +1,312 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Command artbench compares strategies over a fixed set of seeds.
+//
+// Every configuration draws once per seed, one run at a time, so that the
+// results are the same on every run. Each final picture is scored with the
+// same rating. The report shows the mean with a bootstrap confidence interval
+// and a paired permutation test of each configuration against the first.
+//
+// Wall time is the only measurement which changes between runs. Use
+// -no-time to leave it out of results which are checked in.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/artist"
+	"github.com/tswast/pixelsketches/village/perception"
+	"github.com/tswast/pixelsketches/village/stats"
+	"github.com/tswast/pixelsketches/village/strategy"
+)
+
+// Struct config is a strategy and the rating it maximizes, written as
+// strategy:rating.
+type config struct {
+	Name     string
+	Strategy string
+	Rating   string
+}
+
+func parseConfig(spec string) (config, error) {
+	c := config{Name: spec, Strategy: spec, Rating: "whole"}
+	if i := strings.Index(spec, ":"); i >= 0 {
+		c.Strategy, c.Rating = spec[:i], spec[i+1:]
+	}
+	if _, ok := perception.Ratings[c.Rating]; !ok {
+		return c, fmt.Errorf("Unexpected rating in %q", spec)
+	}
+	if _, err := c.New(0); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// New creates the strategy for a seed. Strategies keep state, so use a new
+// one for every run.
+func (c config) New(seed int64) (strategy.Strategizer, error) {
+	switch c.Strategy {
+	case "random":
+		return &strategy.RandomWalk{}, nil
+	case "ideal":
+		return &strategy.Ideal{Rating: perception.Ratings[c.Rating]}, nil
+	case "dictator":
+		return &strategy.Ideal{Rating: perception.RateBlack}, nil
+	case "plurality":
+		var voters []*strategy.Ideal
+		for i, ideal := range perception.DefaultInterests {
+			voters = append(voters, &strategy.Ideal{
+				Rating: perception.NewRating(ideal, palettes.PICO8[i]),
+				Rand:   rand.New(rand.NewSource(seed + int64(i))),
+			})
+		}
+		return &strategy.Plurality{Voters: voters}, nil
+	}
+	return nil, fmt.Errorf("Unexpected strategy in %q", c.Name)
+}
+
+// Struct run is the result of drawing with one seed.
+type run struct {
+	Seed    int64
+	Score   float64
+	Frames  int
+	Exit    string
+	Seconds float64
+}
+
+func bench(c config, seeds []int64, maxIter int, score perception.Rating) ([]run, error) {
+	var runs []run
+	for _, seed := range seeds {
+		s, err := c.New(seed)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		res, err := artist.Run(artist.Options{Seed: seed, MaxIter: maxIter}, s)
+		if err != nil {
+			return nil, err
+		}
+		r := run{
+			Seed:    seed,
+			Score:   score(res.Image),
+			Frames:  res.Frames,
+			Exit:    res.Exit,
+			Seconds: time.Since(start).Seconds(),
+		}
+		log.Printf("%s seed %d: score %f in %d frames (%s)\n", c.Name, seed, r.Score, r.Frames, r.Exit)
+		runs = append(runs, r)
+	}
+	return runs, nil
+}
+
+func scores(runs []run) []float64 {
+	var xs []float64
+	for _, r := range runs {
+		xs = append(xs, r.Score)
+	}
+	return xs
+}
+
+func frames(runs []run) []float64 {
+	var xs []float64
+	for _, r := range runs {
+		xs = append(xs, float64(r.Frames))
+	}
+	return xs
+}
+
+func seconds(runs []run) []float64 {
+	var xs []float64
+	for _, r := range runs {
+		xs = append(xs, r.Seconds)
+	}
+	return xs
+}
+
+// exits counts the exit reasons, such as "done=2 revisit=3".
+func exits(runs []run) string {
+	counts := make(map[string]int)
+	for _, r := range runs {
+		counts[r.Exit]++
+	}
+	var names []string
+	for n := range counts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, n := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", n, counts[n]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Struct reporter writes the summary.
+type reporter struct {
+	level     float64
+	resamples int
+	statsSeed int64
+	noTime    bool
+}
+
+// rng returns a new random source, so that every statistic is the same no
+// matter which others are calculated.
+func (rp *reporter) rng() *rand.Rand {
+	return rand.New(rand.NewSource(rp.statsSeed))
+}
+
+func (rp *reporter) interval(xs []float64, format string) string {
+	lo, hi := stats.BootstrapCI(xs, rp.level, rp.resamples, rp.rng())
+	return fmt.Sprintf(format+" ["+format+", "+format+"]", stats.Mean(xs), lo, hi)
+}
+
+func (rp *reporter) write(w io.Writer, configs []config, results [][]run) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	ci := fmt.Sprintf("%.0f%% CI", rp.level*100)
+	header := "config\tn\tscore mean [" + ci + "]\tframes mean [" + ci + "]\t"
+	if !rp.noTime {
+		header += "seconds mean\t"
+	}
+	fmt.Fprintln(tw, header+"exits")
+	for i, c := range configs {
+		rs := results[i]
+		line := fmt.Sprintf("%s\t%d\t%s\t%s\t", c.Name, len(rs), rp.interval(scores(rs), "%.6f"), rp.interval(frames(rs), "%.1f"))
+		if !rp.noTime {
+			line += fmt.Sprintf("%.3f\t", stats.Mean(seconds(rs)))
+		}
+		fmt.Fprintln(tw, line+exits(rs))
+	}
+	tw.Flush()
+
+	if len(configs) < 2 {
+		return
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "vs %s\tscore diff\tp-value\tframes diff\tp-value\n", configs[0].Name)
+	base := results[0]
+	for i := 1; i < len(configs); i++ {
+		rs := results[i]
+		fmt.Fprintf(tw, "%s\t%+.6f\t%.4f\t%+.1f\t%.4f\n",
+			configs[i].Name,
+			stats.Mean(scores(rs))-stats.Mean(scores(base)),
+			stats.PairedPermutationTest(scores(rs), scores(base), rp.resamples, rp.rng()),
+			stats.Mean(frames(rs))-stats.Mean(frames(base)),
+			stats.PairedPermutationTest(frames(rs), frames(base), rp.resamples, rp.rng()))
+	}
+	tw.Flush()
+}
+
+func writeCSV(p string, configs []config, results [][]run, noTime bool) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %s", p, err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	header := []string{"config", "seed", "score", "frames", "exit"}
+	if !noTime {
+		header = append(header, "seconds")
+	}
+	w.Write(header)
+	for i, c := range configs {
+		for _, r := range results[i] {
+			row := []string{
+				c.Name,
+				strconv.FormatInt(r.Seed, 10),
+				strconv.FormatFloat(r.Score, 'f', -1, 64),
+				strconv.Itoa(r.Frames),
+				r.Exit,
+			}
+			if !noTime {
+				row = append(row, strconv.FormatFloat(r.Seconds, 'f', 3, 64))
+			}
+			w.Write(row)
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func main() {
+	var cs string
+	var n int
+	var seed int
+	var maxIter int
+	var sn string
+	var cp string
+	var op string
+	rp := &reporter{}
+	flag.StringVar(&cs, "configs", "ideal:whole,plurality", "Comma-separated strategy:rating configurations. The first is the baseline.")
+	flag.IntVar(&n, "seeds", 10, "Number of seeds to draw each configuration with.")
+	flag.IntVar(&seed, "seed", 19700101, "First seed.")
+	flag.IntVar(&maxIter, "max-iter", 1000, "Maximum number of iterations for each picture.")
+	flag.StringVar(&sn, "score", "whole", "Name of the rating to score every picture with.")
+	flag.Float64Var(&rp.level, "level", 0.95, "Confidence level of the intervals.")
+	flag.IntVar(&rp.resamples, "resamples", stats.DefaultResamples, "Number of bootstrap and permutation resamples.")
+	flag.Int64Var(&rp.statsSeed, "stats-seed", 1, "Seed used for resampling.")
+	flag.BoolVar(&rp.noTime, "no-time", false, "Leave out wall time, so that the output is deterministic.")
+	flag.StringVar(&cp, "csv", "", "Path to write the result of every run to.")
+	flag.StringVar(&op, "out", "", "Path to write the report to. Default is stdout.")
+	flag.Parse()
+	if rp.resamples < 1 {
+		log.Fatalf("Value for -resamples must be at least 1, got %d.", rp.resamples)
+	}
+
+	score, ok := perception.Ratings[sn]
+	if !ok {
+		log.Fatalf("Unexpected value for score: %q", sn)
+	}
+	var configs []config
+	for _, spec := range strings.Split(cs, ",") {
+		c, err := parseConfig(strings.TrimSpace(spec))
+		if err != nil {
+			log.Fatal(err)
+		}
+		configs = append(configs, c)
+	}
+	var seeds []int64
+	for i := 0; i < n; i++ {
+		seeds = append(seeds, int64(seed+i))
+	}
+
+	var results [][]run
+	for _, c := range configs {
+		rs, err := bench(c, seeds, maxIter, score)
+		if err != nil {
+			log.Fatal(err)
+		}
+		results = append(results, rs)
+	}
+
+	if cp != "" {
+		if err := writeCSV(cp, configs, results, rp.noTime); err != nil {
+			log.Fatal(err)
+		}
+	}
+	w := os.Stdout
+	if op != "" {
+		f, err := os.Create(op)
+		if err != nil {
+			log.Fatalf("Error creating %s: %s", op, err)
+		}
+		defer f.Close()
+		w = f
+	}
+	fmt.Fprintf(w, "score: %s, seeds: %d from %d, max-iter: %d\n\n", sn, n, seed, maxIter)
+	rp.write(w, configs, results)
+}
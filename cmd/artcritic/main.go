@@ -0,0 +1,105 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/tswast/pixelsketches/village"
+	"github.com/tswast/pixelsketches/village/critic"
+	"github.com/tswast/pixelsketches/village/perception"
+	"github.com/tswast/pixelsketches/village/strategy"
+)
+
+func writeWork(dir string, gen int, w critic.Work) {
+	p := filepath.Join(dir, fmt.Sprintf("gen-%04d-%s.png", gen, w.Artist))
+	f, err := os.Create(p)
+	if err != nil {
+		log.Printf("Could not create %s %s\n", p, err)
+		return
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	if err := png.Encode(bw, w.Image); err != nil {
+		log.Printf("Could not encode %s %s\n", p, err)
+	}
+	bw.Flush()
+}
+
+// paint lets an artist draw alone and returns the finished image.
+func paint(name string, in perception.Interests, maxIter int) image.Image {
+	v := village.New(&village.RoundRobin{})
+	v.AddArtist(name, &strategy.Ideal{Rating: in.Rate}, in.Rate)
+	v.Run(maxIter, nil)
+	return v.Image
+}
+
+func main() {
+	var seed int
+	var maxIter int
+	var gens int
+	var n int
+	var drift float64
+	var sp string
+	var gallery string
+	flag.IntVar(&seed, "seed", 19700101, "Seed used for random number generator.")
+	flag.IntVar(&maxIter, "max-iter", 1000, "Maximum number of iterations per painting.")
+	flag.IntVar(&gens, "generations", 1, "Number of exhibitions to hold.")
+	flag.IntVar(&n, "artists", 4, "Number of artists in a new store.")
+	flag.Float64Var(&drift, "drift", 0.05, "How far artists move their interests toward the best work.")
+	flag.StringVar(&sp, "store", "critic.json", "Path to the store of reputations and interests.")
+	flag.StringVar(&gallery, "gallery", "", "Directory to write each generation's work to.")
+	flag.Parse()
+
+	s, err := critic.Load(sp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Seed with the generation too, so that continuing a run doesn't repeat it.
+	rand.Seed(int64(seed) + int64(s.Generation))
+	if len(s.Artists) == 0 {
+		for i := 0; i < n; i++ {
+			p := perception.NewPersonality(rand.Int63())
+			s.Artists[fmt.Sprintf("artist-%d", i)] = &critic.Record{Interests: p.Interests}
+		}
+	}
+
+	for g := 0; g < gens; g++ {
+		var works []critic.Work
+		var critics []critic.Critic
+		for _, name := range s.Names() {
+			in := s.Artists[name].Interests
+			log.Printf("generation %d: %s is painting\n", s.Generation, name)
+			w := critic.Work{Artist: name, Image: paint(name, in, maxIter)}
+			works = append(works, w)
+			critics = append(critics, critic.Critic{Name: name, Rating: in.Rate})
+			if gallery != "" {
+				writeWork(gallery, s.Generation, w)
+			}
+		}
+
+		reviews := critic.Exhibit(works, critics)
+		scores := critic.Scores(reviews)
+		for _, name := range s.Names() {
+			fmt.Printf("generation: %d %s: score: %f\n", s.Generation, name, scores[name])
+		}
+		s.Record(works, reviews, drift)
+		if err := s.Save(sp); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, name := range s.Names() {
+		fmt.Printf("%s: reputation: %f\n", name, s.Artists[name].Reputation)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	"github.com/tswast/pixelsketches/village/evolve"
+	"github.com/tswast/pixelsketches/village/gui"
+	"github.com/tswast/pixelsketches/village/perception"
+)
+
+func writeImage(p string, im image.Image) error {
+	f, err := os.Create(p)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %s", p, err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := png.Encode(w, im); err != nil {
+		return fmt.Errorf("Error encoding %s: %s", p, err)
+	}
+	return w.Flush()
+}
+
+func main() {
+	var seed int
+	var gens int
+	var inp string
+	var p string
+	var dir string
+	var cp string
+	var rn string
+	var ap string
+	cfg := evolve.DefaultConfig
+	flag.IntVar(&seed, "seed", 19700101, "Seed used for random number generator.")
+	flag.IntVar(&gens, "generations", 100, "Number of generations.")
+	flag.IntVar(&cfg.Size, "size", cfg.Size, "Number of individuals in the population.")
+	flag.IntVar(&cfg.Elites, "elites", cfg.Elites, "Number of best individuals kept each generation.")
+	flag.IntVar(&cfg.Tournament, "tournament", cfg.Tournament, "Number of individuals competing to be a parent.")
+	flag.Float64Var(&cfg.Crossover, "crossover", cfg.Crossover, "Probability of combining two parents.")
+	flag.IntVar(&cfg.Pixels, "pixels", cfg.Pixels, "Number of pixels mutated in each child.")
+	flag.Float64Var(&cfg.Rect, "rect", cfg.Rect, "Probability of painting a rectangle in each child.")
+	flag.StringVar(&rn, "rating", "whole", "Name of the rating to maximize.")
+	flag.StringVar(&ap, "artist", "", "Path to a personality profile to rate with instead of -rating.")
+	flag.StringVar(&inp, "in", "", "Path to input file to start from.")
+	flag.StringVar(&p, "out", "", "Path to output file for the best individual.")
+	flag.StringVar(&dir, "out-dir", "", "Directory to write the best individual of each generation to.")
+	flag.StringVar(&cp, "csv", "", "Path to write fitness per generation to.")
+	flag.Parse()
+	if cfg.Size < 1 {
+		log.Fatalf("Value for -size must be at least 1, got %d.", cfg.Size)
+	}
+	if cfg.Elites < 0 || cfg.Elites > cfg.Size {
+		log.Fatalf("Value for -elites must be between 0 and -size, got %d.", cfg.Elites)
+	}
+	if p == "" {
+		log.Fatal("Value for -out is missing.")
+	}
+
+	rating, ok := perception.Ratings[rn]
+	if ap != "" {
+		pers, err := perception.LoadPersonality(ap)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rating = pers.Rate
+	} else if !ok {
+		log.Fatalf("Unexpected value for rating: %q", rn)
+	}
+
+	start := gui.NewAppState().Image
+	if inp != "" {
+		f, err := os.Open(inp)
+		if err != nil {
+			log.Fatalf("Error opening %s: %s", inp, err)
+		}
+		im, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Error decoding %s: %s", inp, err)
+		}
+		draw.Draw(start, start.Bounds(), im, image.ZP, draw.Src)
+	}
+
+	var cw *csv.Writer
+	if cp != "" {
+		f, err := os.Create(cp)
+		if err != nil {
+			log.Fatalf("Error creating %s: %s", cp, err)
+		}
+		defer f.Close()
+		cw = csv.NewWriter(f)
+		defer cw.Flush()
+		cw.Write([]string{"generation", "best", "mean", "worst"})
+	}
+
+	pop := evolve.New(start, rating, cfg, rand.New(rand.NewSource(int64(seed))))
+	for {
+		best := pop.Best()
+		worst := pop.Individuals[len(pop.Individuals)-1]
+		log.Printf("generation: %d best: %f mean: %f\n", pop.Generation, best.Fitness, pop.Mean())
+		if cw != nil {
+			cw.Write([]string{
+				fmt.Sprint(pop.Generation),
+				fmt.Sprint(best.Fitness),
+				fmt.Sprint(pop.Mean()),
+				fmt.Sprint(worst.Fitness),
+			})
+		}
+		if dir != "" {
+			gp := filepath.Join(dir, fmt.Sprintf("gen-%04d.png", pop.Generation))
+			if err := writeImage(gp, best.Image); err != nil {
+				log.Print(err)
+			}
+		}
+		if pop.Generation >= gens {
+			break
+		}
+		pop.Evolve()
+	}
+
+	fmt.Printf("best: %f\n", pop.Best().Fitness)
+	if err := writeImage(p, pop.Best().Image); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -5,8 +5,11 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/tswast/pixelsketches/palettes"
 	"github.com/tswast/pixelsketches/village/artist"
@@ -14,6 +17,21 @@ import (
 	"github.com/tswast/pixelsketches/village/strategy"
 )
 
+// startRemote runs or connects to the bot for the remote strategy.
+func startRemote(bot string) (*strategy.Remote, error) {
+	if strings.HasPrefix(bot, "tcp://") {
+		return strategy.DialRemote("tcp", strings.TrimPrefix(bot, "tcp://"))
+	}
+	if strings.HasPrefix(bot, "unix://") {
+		return strategy.DialRemote("unix", strings.TrimPrefix(bot, "unix://"))
+	}
+	args := strings.Fields(bot)
+	if len(args) == 0 {
+		return nil, errors.New("Value for -remote is missing.")
+	}
+	return strategy.StartRemote(args[0], args[1:]...)
+}
+
 func main() {
 	var seed int
 	var maxIter int
@@ -22,49 +40,131 @@ func main() {
 	var inp string
 	var p string
 	var st string
+	var ap string
+	var wp string
+	var budget time.Duration
+	var tp string
+	var dd string
+	var dg string
+	var ds int
+	var tv bool
+	var addr string
+	var rb string
+	var lp string
+	var delay time.Duration
 	flag.IntVar(&seed, "seed", 19700101, "Seed used for random number generator.")
 	flag.IntVar(&maxIter, "max-iter", 1000000, "Maximum number of iterations.")
 	flag.BoolVar(&tl, "timelapse", false, "Write timelapse to out/ directory.")
 	flag.BoolVar(&debug, "debug", false, "Write current frame and action.")
 	flag.StringVar(&inp, "in", "", "Path to input file to start edit with.")
 	flag.StringVar(&p, "out", "", "Path to output file.")
-	flag.StringVar(&st, "strategy", "random", "Strategy to use: random|ideal")
+	flag.StringVar(&st, "strategy", "random", "Strategy to use: random|ideal|dictator|plurality|qlearn|remote")
+	flag.StringVar(&ap, "artist", "", "Path to a personality profile. Its preferred tool is the default strategy.")
+	flag.StringVar(&wp, "weights", "", "Path to trained weights for the qlearn strategy.")
+	flag.DurationVar(&budget, "budget", 0, "Time limit for choosing each action, such as 50ms. Zero means no limit.")
+	flag.StringVar(&tp, "trace", "", "Path to write the decision trace of every frame to, as JSON lines.")
+	flag.StringVar(&dd, "debug-dir", "", "Directory to write annotated frames showing candidate ratings to.")
+	flag.StringVar(&dg, "debug-gif", "", "Path to write annotated frames to as a GIF. Use with -max-iter.")
+	flag.IntVar(&ds, "debug-scale", 8, "How much to upscale annotated frames.")
+	flag.BoolVar(&tv, "tui", false, "Draw every frame to the terminal with true color half blocks.")
+	flag.StringVar(&addr, "serve", "", "Address such as :8080 to serve a live viewer on, instead of exiting when done.")
+	flag.DurationVar(&delay, "delay", 100*time.Millisecond, "Time between frames when serving.")
+	flag.StringVar(&rb, "remote", "", "Bot for the remote strategy: a command to run, tcp://host:port or unix:///path.")
+	flag.StringVar(&lp, "learned", "", "Path to a learned rating from cmd/artlabel for the ideal strategy to maximize.")
 	flag.Parse()
 	if p == "" {
 		log.Fatal("Value for -out is missing.")
 	}
 
+	var pers *perception.Personality
+	if ap != "" {
+		var err error
+		pers, err = perception.LoadPersonality(ap)
+		if err != nil {
+			log.Fatal(err)
+		}
+		stSet := false
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "strategy" {
+				stSet = true
+			}
+		})
+		if !stSet {
+			st = pers.Tool()
+		}
+	}
+
 	var s strategy.Strategizer
 	if st == "random" {
 		s = &strategy.RandomWalk{}
+	} else if st == "ideal" && lp != "" {
+		l, err := perception.LoadLearned(lp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s = &strategy.Ideal{Rating: l.Rate}
+	} else if st == "ideal" && pers != nil {
+		s = &strategy.Ideal{Rating: pers.Rate}
 	} else if st == "ideal" {
 		s = &strategy.Ideal{Rating: perception.RateWholeImage}
 	} else if st == "dictator" {
 		s = &strategy.Ideal{Rating: perception.RateBlack}
 	} else if st == "plurality" {
-		s = &strategy.Plurality{[]*strategy.Ideal{
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealBlack, palettes.PICO8_BLACK)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealDarkBlue, palettes.PICO8_DARK_BLUE)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealDarkPurple, palettes.PICO8_DARK_PURPLE)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealDarkGreen, palettes.PICO8_DARK_GREEN)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealBrown, palettes.PICO8_BROWN)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealDarkGray, palettes.PICO8_DARK_GRAY)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealLightGray, palettes.PICO8_LIGHT_GRAY)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealWhite, palettes.PICO8_WHITE)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealRed, palettes.PICO8_RED)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealOrange, palettes.PICO8_ORANGE)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealYellow, palettes.PICO8_YELLOW)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealGreen, palettes.PICO8_GREEN)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealBlue, palettes.PICO8_BLUE)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealIndigo, palettes.PICO8_INDIGO)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealPink, palettes.PICO8_PINK)},
-			&strategy.Ideal{Rating: perception.NewRating(perception.IdealPeach, palettes.PICO8_PEACH)},
-		}}
+		in := perception.DefaultInterests
+		if pers != nil {
+			in = pers.Interests
+		}
+		var voters []*strategy.Ideal
+		for i, ideal := range in {
+			voters = append(voters, &strategy.Ideal{Rating: perception.NewRating(ideal, palettes.PICO8[i])})
+		}
+		s = &strategy.Plurality{Voters: voters}
+	} else if st == "qlearn" {
+		rating := perception.RateWholeImage
+		if pers != nil {
+			rating = pers.Rate
+		}
+		q, err := strategy.LoadQLearner(wp, rating)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s = q
+	} else if st == "remote" {
+		rm, err := startRemote(rb)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer rm.Close()
+		s = rm
 	} else {
 		log.Fatal("Unexpected value for strategy.")
 	}
 
-	if err := artist.Main(inp, p, int64(seed), debug, tl, maxIter, s); err != nil {
+	opts := artist.Options{
+		InPath:     inp,
+		OutPath:    p,
+		Seed:       int64(seed),
+		Debug:      debug,
+		TimeLapse:  tl,
+		MaxIter:    maxIter,
+		Budget:     budget,
+		TracePath:  tp,
+		DebugDir:   dd,
+		DebugGIF:   dg,
+		DebugScale: ds,
+		TUI:        tv,
+	}
+	if addr != "" {
+		if err := serve(addr, delay, opts, s); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := artist.Main(opts, s); err != nil {
+		// Fatal doesn't run deferred calls, so stop the bot first.
+		if rm, ok := s.(*strategy.Remote); ok {
+			rm.Close()
+		}
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,257 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"image/png"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/tswast/pixelsketches/village/artist"
+	"github.com/tswast/pixelsketches/village/gui"
+	"github.com/tswast/pixelsketches/village/strategy"
+)
+
+//go:embed viewer.html
+var viewerHTML []byte
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// Struct update is sent over the websocket whenever the run changes.
+type update struct {
+	Seq     int          `json:"seq"`
+	Frame   artist.Frame `json:"frame"`
+	Paused  bool         `json:"paused"`
+	DelayMS int64        `json:"delay_ms"`
+	Done    bool         `json:"done"`
+}
+
+// Struct command is received over the websocket to control the run.
+type command struct {
+	// Command is one of pause, resume, step or speed.
+	Command string `json:"command"`
+	// DelayMS is the time between frames for the speed command.
+	DelayMS int64 `json:"delay_ms"`
+}
+
+// maxTrace is the number of recent frames kept for /trace, so that a long
+// session doesn't grow without bound.
+const maxTrace = 10000
+
+// Struct server runs an artist and streams its frames.
+//
+// Use cond to make sure the handlers can read and the artist can write
+// without conflict. Every change increments seq, so that waiting
+// websockets know to send an update.
+type server struct {
+	cond   *sync.Cond
+	seq    int
+	frame  artist.Frame
+	app    *gui.AppState
+	trace  []artist.Frame
+	paused bool
+	steps  int
+	delay  time.Duration
+	done   bool
+}
+
+func newServer(delay time.Duration) *server {
+	return &server{
+		cond:  sync.NewCond(&sync.Mutex{}),
+		app:   gui.NewAppState(),
+		delay: delay,
+	}
+}
+
+// onFrame records a frame, then waits while paused and for the delay.
+func (s *server) onFrame(fr artist.Frame, app *gui.AppState) {
+	s.cond.L.Lock()
+	s.frame = fr
+	s.app = gui.CopyAppState(app)
+	s.trace = append(s.trace, fr)
+	if len(s.trace) > maxTrace {
+		s.trace = s.trace[len(s.trace)-maxTrace:]
+	}
+	s.seq++
+	s.cond.Broadcast()
+	for s.paused && s.steps == 0 {
+		s.cond.Wait()
+	}
+	if s.steps > 0 {
+		s.steps--
+	}
+	delay := s.delay
+	s.cond.L.Unlock()
+	time.Sleep(delay)
+}
+
+// run draws with the artist, then marks the server done.
+func (s *server) run(opts artist.Options, st strategy.Strategizer) {
+	opts.OnFrame = s.onFrame
+	if err := artist.Main(opts, st); err != nil {
+		log.Printf("Error running artist: %s\n", err)
+	}
+	s.cond.L.Lock()
+	s.done = true
+	s.seq++
+	s.cond.Broadcast()
+	s.cond.L.Unlock()
+}
+
+func (s *server) control(c command) {
+	s.cond.L.Lock()
+	defer s.cond.L.Unlock()
+	switch c.Command {
+	case "pause":
+		s.paused = true
+	case "resume":
+		s.paused = false
+	case "step":
+		s.steps++
+	case "speed":
+		if c.DelayMS >= 0 {
+			s.delay = time.Duration(c.DelayMS) * time.Millisecond
+		}
+	default:
+		log.Printf("Unexpected command %q\n", c.Command)
+		return
+	}
+	s.seq++
+	s.cond.Broadcast()
+}
+
+// current returns an update for the latest state. Hold the lock to call it.
+func (s *server) current() update {
+	return update{
+		Seq:     s.seq,
+		Frame:   s.frame,
+		Paused:  s.paused,
+		DelayMS: int64(s.delay / time.Millisecond),
+		Done:    s.done,
+	}
+}
+
+func (s *server) socketHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Error upgrading to websocket: %q", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	// Listen for commands over the websocket. When the viewer disconnects,
+	// wake the writer below so that it stops too, even if the run is over.
+	closed := false
+	go func(c *websocket.Conn) {
+		for {
+			var cmd command
+			if err := c.ReadJSON(&cmd); err != nil {
+				c.Close()
+				s.cond.L.Lock()
+				closed = true
+				s.cond.Broadcast()
+				s.cond.L.Unlock()
+				return
+			}
+			s.control(cmd)
+		}
+	}(conn)
+
+	prev := -1
+	for {
+		s.cond.L.Lock()
+		for prev == s.seq && !closed {
+			s.cond.Wait()
+		}
+		if closed {
+			s.cond.L.Unlock()
+			return
+		}
+		u := s.current()
+		prev = s.seq
+		s.cond.L.Unlock()
+
+		if err := conn.WriteJSON(&u); err != nil {
+			log.Printf("Error writing update: %q", err.Error())
+			return
+		}
+	}
+}
+
+func (s *server) viewHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(viewerHTML)
+}
+
+// screenHandler writes the screen at the latest frame.
+func (s *server) screenHandler(w http.ResponseWriter, r *http.Request) {
+	s.cond.L.Lock()
+	app := gui.CopyAppState(s.app)
+	s.cond.L.Unlock()
+
+	w.Header().Set("Content-Type", "image/png")
+	if err := png.Encode(w, app.DrawScreen()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// imageHandler writes the picture, which is the output file once done.
+func (s *server) imageHandler(outPath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.cond.L.Lock()
+		done := s.done
+		app := gui.CopyAppState(s.app)
+		s.cond.L.Unlock()
+
+		if done {
+			http.ServeFile(w, r, outPath)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		if err := png.Encode(w, app.Image); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// traceHandler writes the decision trace of the last maxTrace frames as JSON
+// lines.
+func (s *server) traceHandler(w http.ResponseWriter, r *http.Request) {
+	s.cond.L.Lock()
+	trace := s.trace
+	s.cond.L.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	for i := range trace {
+		if err := enc.Encode(&trace[i]); err != nil {
+			log.Printf("Error writing trace: %s\n", err)
+			return
+		}
+	}
+}
+
+// serve runs the artist in the background and serves it at addr.
+func serve(addr string, delay time.Duration, opts artist.Options, st strategy.Strategizer) error {
+	s := newServer(delay)
+	go s.run(opts, st)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/socket", s.socketHandler)
+	mux.HandleFunc("/", s.viewHandler)
+	mux.HandleFunc("/screen.png", s.screenHandler)
+	mux.HandleFunc("/out.png", s.imageHandler(opts.OutPath))
+	mux.HandleFunc("/trace", s.traceHandler)
+	log.Printf("serving on %s\n", addr)
+	return http.ListenAndServe(addr, mux)
+}
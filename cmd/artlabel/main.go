@@ -0,0 +1,333 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Command artlabel records which of two pictures a human prefers.
+//
+// It shows random pairs of the given PNG files or globs, in the terminal or
+// on a local web page with -http, and appends each choice to a JSON lines
+// dataset. With -fit, it fits a perception.Learned rating to the dataset
+// instead.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"image"
+	"image/draw"
+	"image/png"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/perception"
+	"github.com/tswast/pixelsketches/village/tui"
+)
+
+// Struct label is one human choice in the dataset.
+type label struct {
+	Winner string `json:"winner"`
+	Loser  string `json:"loser"`
+}
+
+// Struct labeler chooses pairs and records labels.
+type labeler struct {
+	paths []string
+	data  string
+	rng   *rand.Rand
+	mu    sync.Mutex
+}
+
+// pair returns the indexes of two different random images.
+func (l *labeler) pair() (int, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a := l.rng.Intn(len(l.paths))
+	b := l.rng.Intn(len(l.paths) - 1)
+	if b >= a {
+		b++
+	}
+	return a, b
+}
+
+// record appends a label to the dataset.
+func (l *labeler) record(winner, loser int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.data, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Error opening %s: %s", l.data, err)
+	}
+	defer f.Close()
+	lb := label{Winner: l.paths[winner], Loser: l.paths[loser]}
+	if err := json.NewEncoder(f).Encode(&lb); err != nil {
+		return fmt.Errorf("Error writing %s: %s", l.data, err)
+	}
+	return nil
+}
+
+func readImage(p string) (image.Image, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening %s: %s", p, err)
+	}
+	defer f.Close()
+	im, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding %s: %s", p, err)
+	}
+	return im, nil
+}
+
+// sideBySide draws two images next to each other, with a gap.
+func sideBySide(a, b image.Image) image.Image {
+	const gap = 4
+	ab, bb := a.Bounds(), b.Bounds()
+	h := ab.Dy()
+	if bb.Dy() > h {
+		h = bb.Dy()
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, ab.Dx()+gap+bb.Dx(), h))
+	draw.Draw(out, ab.Sub(ab.Min), a, ab.Min, draw.Src)
+	draw.Draw(out, bb.Sub(bb.Min).Add(image.Point{X: ab.Dx() + gap}), b, bb.Min, draw.Src)
+	return out
+}
+
+// terminal labels pairs in the terminal until the human quits.
+func (l *labeler) terminal() error {
+	in := bufio.NewReader(os.Stdin)
+	for {
+		a, b := l.pair()
+		ima, err := readImage(l.paths[a])
+		if err != nil {
+			return err
+		}
+		imb, err := readImage(l.paths[b])
+		if err != nil {
+			return err
+		}
+		fmt.Print(tui.Clear + tui.Home)
+		if err := tui.Draw(os.Stdout, sideBySide(ima, imb)); err != nil {
+			return err
+		}
+		fmt.Printf("1: %s\n2: %s\n", l.paths[a], l.paths[b])
+		fmt.Print("Which do you prefer? [1] left, [2] right, [s]kip, [q]uit: ")
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return nil
+		}
+		switch strings.TrimSpace(line) {
+		case "1":
+			err = l.record(a, b)
+		case "2":
+			err = l.record(b, a)
+		case "q":
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<meta charset="utf-8">
+<title>pixelsketches - which do you prefer?</title>
+<style>
+  .pixel-art {
+    image-rendering: optimize-contrast;
+    image-rendering: pixelated;
+    border: 2px solid black;
+    width: 384px;
+  }
+  form {
+    display: inline-block;
+    margin: 8px;
+    text-align: center;
+  }
+</style>
+<p>Which do you prefer? <a href="/">Skip</a></p>
+<form method="post" action="/choose">
+  <input type="hidden" name="winner" value="{{.A}}">
+  <input type="hidden" name="loser" value="{{.B}}">
+  <button><img class="pixel-art" src="/image?i={{.A}}" alt="left"></button>
+</form>
+<form method="post" action="/choose">
+  <input type="hidden" name="winner" value="{{.B}}">
+  <input type="hidden" name="loser" value="{{.A}}">
+  <button><img class="pixel-art" src="/image?i={{.B}}" alt="right"></button>
+</form>
+`))
+
+func (l *labeler) formIndex(r *http.Request, name string) (int, error) {
+	i, err := strconv.Atoi(r.FormValue(name))
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 || i >= len(l.paths) {
+		return 0, fmt.Errorf("invalid image index %d", i)
+	}
+	return i, nil
+}
+
+func (l *labeler) viewHandler(w http.ResponseWriter, r *http.Request) {
+	a, b := l.pair()
+	if err := page.Execute(w, struct{ A, B int }{a, b}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (l *labeler) imageHandler(w http.ResponseWriter, r *http.Request) {
+	i, err := l.formIndex(r, "i")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.ServeFile(w, r, l.paths[i])
+}
+
+func (l *labeler) chooseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "expected POST", http.StatusMethodNotAllowed)
+		return
+	}
+	winner, err := l.formIndex(r, "winner")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	loser, err := l.formIndex(r, "loser")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := l.record(winner, loser); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (l *labeler) serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", l.viewHandler)
+	mux.HandleFunc("/image", l.imageHandler)
+	mux.HandleFunc("/choose", l.chooseHandler)
+	log.Printf("serving on %s\n", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+func readLabels(p string) ([]label, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening %s: %s", p, err)
+	}
+	defer f.Close()
+	var labels []label
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var lb label
+		if err := dec.Decode(&lb); err != nil {
+			return nil, fmt.Errorf("Error decoding %s: %s", p, err)
+		}
+		labels = append(labels, lb)
+	}
+	return labels, nil
+}
+
+// fit fits a learned rating to the dataset and saves it.
+func fit(data, out string, epochs int, rate, l2 float64) error {
+	labels, err := readLabels(data)
+	if err != nil {
+		return err
+	}
+	names := perception.FeatureNames()
+	features := make(map[string][]float64)
+	feat := func(p string) ([]float64, error) {
+		if f, ok := features[p]; ok {
+			return f, nil
+		}
+		im, err := readImage(p)
+		if err != nil {
+			return nil, err
+		}
+		pim, _ := perception.ToPalette(im, palettes.PICO8)
+		f, err := perception.Features(pim, names)
+		if err != nil {
+			return nil, err
+		}
+		features[p] = f
+		return f, nil
+	}
+	var cs []perception.Comparison
+	for _, lb := range labels {
+		w, err := feat(lb.Winner)
+		if err != nil {
+			return err
+		}
+		l, err := feat(lb.Loser)
+		if err != nil {
+			return err
+		}
+		cs = append(cs, perception.Comparison{Winner: w, Loser: l})
+	}
+	learned := perception.FitLearned(names, cs, epochs, rate, l2)
+	fmt.Printf("labels: %d\naccuracy: %f\n", len(cs), learned.Accuracy(cs))
+	return learned.Save(out)
+}
+
+func main() {
+	var data string
+	var addr string
+	var fp string
+	var seed int
+	var epochs int
+	var rate float64
+	var l2 float64
+	flag.StringVar(&data, "data", "labels.jsonl", "Path to the dataset of labels, as JSON lines.")
+	flag.StringVar(&addr, "http", "", "Address such as :8081 to label on a web page instead of the terminal.")
+	flag.StringVar(&fp, "fit", "", "Path to write a learned rating fitted to the dataset to, instead of labeling.")
+	flag.IntVar(&seed, "seed", 19700101, "Seed used for choosing pairs.")
+	flag.IntVar(&epochs, "epochs", 2000, "Number of gradient descent steps for -fit.")
+	flag.Float64Var(&rate, "rate", 1.0, "Learning rate for -fit.")
+	flag.Float64Var(&l2, "l2", 0.01, "L2 regularization for -fit.")
+	flag.Parse()
+
+	if fp != "" {
+		if err := fit(data, fp, epochs, rate, l2); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var paths []string
+	for _, a := range flag.Args() {
+		ms, err := filepath.Glob(a)
+		if err != nil {
+			log.Fatalf("Bad pattern %q: %s", a, err)
+		}
+		paths = append(paths, ms...)
+	}
+	if len(paths) < 2 {
+		log.Fatal("Expected at least two PNG files to compare.")
+	}
+	l := &labeler{paths: paths, data: data, rng: rand.New(rand.NewSource(int64(seed)))}
+	var err error
+	if addr != "" {
+		err = l.serve(addr)
+	} else {
+		err = l.terminal()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image/png"
+	"log"
+	"math/rand"
+	"os"
+
+	"github.com/tswast/pixelsketches/village/gui"
+	"github.com/tswast/pixelsketches/village/optimize"
+	"github.com/tswast/pixelsketches/village/perception"
+)
+
+func main() {
+	var seed int
+	var method string
+	var steps int
+	var restarts int
+	var cl string
+	var t0 float64
+	var alpha float64
+	var rn string
+	var ap string
+	var p string
+	flag.IntVar(&seed, "seed", 19700101, "Seed used for random number generator.")
+	flag.StringVar(&method, "method", "anneal", "Optimizer to use: anneal|climb")
+	flag.IntVar(&steps, "steps", 100000, "Number of steps (per restart, when climbing).")
+	flag.IntVar(&restarts, "restarts", 10, "Number of random restarts when climbing.")
+	flag.StringVar(&cl, "cooling", "exponential", "Cooling schedule: linear|exponential|logarithmic")
+	flag.Float64Var(&t0, "t0", 0.01, "Starting temperature.")
+	flag.Float64Var(&alpha, "alpha", 0.9999, "Cooling rate of the exponential schedule.")
+	flag.StringVar(&rn, "rating", "whole", "Name of the rating to maximize.")
+	flag.StringVar(&ap, "artist", "", "Path to a personality profile to rate with instead of -rating.")
+	flag.StringVar(&p, "out", "", "Path to output file.")
+	flag.Parse()
+	if p == "" {
+		log.Fatal("Value for -out is missing.")
+	}
+
+	rating, ok := perception.Ratings[rn]
+	if ap != "" {
+		pers, err := perception.LoadPersonality(ap)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rating = pers.Rate
+	} else if !ok {
+		log.Fatalf("Unexpected value for rating: %q", rn)
+	}
+
+	var cool optimize.Cooling
+	if cl == "linear" {
+		cool = optimize.Linear(t0)
+	} else if cl == "exponential" {
+		cool = optimize.Exponential(t0, alpha)
+	} else if cl == "logarithmic" {
+		cool = optimize.Logarithmic(t0)
+	} else {
+		log.Fatal("Unexpected value for cooling.")
+	}
+
+	rng := rand.New(rand.NewSource(int64(seed)))
+	start := gui.NewAppState().Image
+	var res optimize.Result
+	if method == "anneal" {
+		res = optimize.Anneal(start, rating, steps, cool, rng)
+	} else if method == "climb" {
+		res = optimize.HillClimb(start, rating, steps, restarts, rng)
+	} else {
+		log.Fatal("Unexpected value for method.")
+	}
+	fmt.Printf("rating: %f\nsteps: %d\n", res.Rating, res.Steps)
+
+	f, err := os.Create(p)
+	if err != nil {
+		log.Fatalf("Error creating %s: %s", p, err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := png.Encode(w, res.Image); err != nil {
+		log.Fatalf("Error encoding %s: %s", p, err)
+	}
+	w.Flush()
+}
@@ -0,0 +1,179 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Command artplay lets a human draw with the same gui as the bots.
+//
+// Move the cursor with the arrow keys or hjkl, and diagonally with yubn.
+// Space toggles pressing, since a terminal can't tell when a key is
+// released. Period stays in place. Press q to quit early.
+//
+// The session is recorded just like artgen: a trace of every frame, an
+// optional timelapse and the final picture. Logs would garble the screen, so
+// they go to -log while drawing, or nowhere.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/artist"
+	"github.com/tswast/pixelsketches/village/gui"
+	"github.com/tswast/pixelsketches/village/strategy"
+	"github.com/tswast/pixelsketches/village/tui"
+)
+
+// keys maps single keys to cursor movements.
+var keys = map[string]gui.Action{
+	"h": {Horizontal: -1},
+	"j": {Vertical: 1},
+	"k": {Vertical: -1},
+	"l": {Horizontal: 1},
+	"y": {Horizontal: -1, Vertical: -1},
+	"u": {Horizontal: 1, Vertical: -1},
+	"b": {Horizontal: -1, Vertical: 1},
+	"n": {Horizontal: 1, Vertical: 1},
+	".": {},
+	// Arrow keys, after the escape sequence.
+	"\x1b[A": {Vertical: -1},
+	"\x1b[B": {Vertical: 1},
+	"\x1b[C": {Horizontal: 1},
+	"\x1b[D": {Horizontal: -1},
+}
+
+// stty runs stty on the terminal and returns its output.
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// readKeys sends each key press, including whole escape sequences.
+func readKeys(keyc chan<- string) {
+	r := bufio.NewReader(os.Stdin)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			close(keyc)
+			return
+		}
+		k := string(b)
+		if b == 0x1b && r.Buffered() >= 2 {
+			b1, _ := r.ReadByte()
+			b2, _ := r.ReadByte()
+			k += string([]byte{b1, b2})
+		}
+		keyc <- k
+	}
+}
+
+// Struct Human chooses actions from the keyboard.
+type Human struct {
+	keys    <-chan string
+	sigs    <-chan os.Signal
+	quit    chan struct{}
+	pressed bool
+	frame   int
+}
+
+func (h *Human) stop() (gui.Action, strategy.Rating) {
+	close(h.quit)
+	return gui.Action{}, strategy.Rating{Reason: &strategy.SimpleReason{Reason: "quit"}}
+}
+
+// Strategize draws the screen and waits for a key.
+func (h *Human) Strategize(app *gui.AppState) (gui.Action, strategy.Rating) {
+	status := fmt.Sprintf("frame: %d  cursor: %v  pressed: %v  color: %s", h.frame, app.Cursor.Pos, h.pressed, palettes.Hex(app.Color))
+	help := "arrows/hjkl/yubn: move  space: press/release  .: stay  q: quit"
+	if err := tui.Frame(os.Stdout, app.DrawScreen(), status, help); err != nil {
+		log.Printf("Could not draw frame %d: %s\n", h.frame, err)
+	}
+	h.frame++
+	for {
+		select {
+		case <-h.sigs:
+			return h.stop()
+		case k, ok := <-h.keys:
+			if !ok || k == "q" || k == "\x03" {
+				return h.stop()
+			}
+			if k == " " {
+				h.pressed = !h.pressed
+				return gui.Action{Pressed: h.pressed}, strategy.Rating{Reason: &strategy.SimpleReason{Reason: "human"}}
+			}
+			if act, ok := keys[k]; ok {
+				act.Pressed = h.pressed
+				return act, strategy.Rating{Reason: &strategy.SimpleReason{Reason: "human"}}
+			}
+		}
+	}
+}
+
+func main() {
+	var tl bool
+	var inp string
+	var p string
+	var tp string
+	var lp string
+	flag.BoolVar(&tl, "timelapse", false, "Write timelapse to out/ directory.")
+	flag.StringVar(&inp, "in", "", "Path to input file to start edit with.")
+	flag.StringVar(&p, "out", "", "Path to output file.")
+	flag.StringVar(&tp, "trace", "", "Path to write the session to, as JSON lines.")
+	flag.StringVar(&lp, "log", "", "Path to write logs to while drawing.")
+	flag.Parse()
+	if p == "" {
+		log.Fatal("Value for -out is missing.")
+	}
+	logw := ioutil.Discard
+	if lp != "" {
+		f, err := os.Create(lp)
+		if err != nil {
+			log.Fatalf("Error creating %s: %s", lp, err)
+		}
+		defer f.Close()
+		logw = f
+	}
+
+	state, err := stty("-g")
+	if err != nil {
+		log.Fatalf("Error reading terminal state: %s", err)
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		log.Fatalf("Error setting terminal mode: %s", err)
+	}
+	defer stty(state)
+
+	keyc := make(chan string)
+	go readKeys(keyc)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	h := &Human{keys: keyc, sigs: sigs, quit: make(chan struct{})}
+
+	log.SetOutput(logw)
+	fmt.Print(tui.Clear + tui.HideCursor)
+	opts := artist.Options{
+		InPath:        inp,
+		OutPath:       p,
+		TimeLapse:     tl,
+		MaxIter:       1000000,
+		TracePath:     tp,
+		AllowRevisits: true,
+		Quit:          h.quit,
+	}
+	err = artist.Main(opts, h)
+	fmt.Print(tui.ShowCursor)
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		stty(state)
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/tswast/pixelsketches/village/perception"
+)
+
+func main() {
+	var seed int
+	var p string
+	flag.IntVar(&seed, "seed", 19700101, "Seed used to generate the personality.")
+	flag.StringVar(&p, "out", "", "Path to output profile.")
+	flag.Parse()
+	if p == "" {
+		log.Fatal("Value for -out is missing.")
+	}
+
+	if err := perception.NewPersonality(int64(seed)).Save(p); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -2,91 +2,340 @@
 // Use of this source code is governed by the Apache 2.0
 // license that can be found in the LICENSE file.
 
+// Command artrate rates PNG files.
+//
+// Arguments are paths or globs. Colors which aren't in the PICO-8 palette are
+// mapped to the nearest palette color, with a warning, except with -cvd.
+//
+// By default, it shows the rating of each color of a personality, its
+// compositions and the final rating. Choose other ratings by name with
+// -ratings, or with a JSON spec file which lists names, such as
+// ["whole", "tl-corners"].
+//
+// With -cvd, it shows how well each file reads for colorblind viewers, and
+// lists the neighboring colors each color vision deficiency confuses. The
+// file's own colors are rated, so any sprite can be checked. It can't be
+// combined with -ratings or -spec.
 package main
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"image"
+	"image/color"
 	"image/png"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/tswast/pixelsketches/palettes"
 	"github.com/tswast/pixelsketches/village/perception"
 )
 
-func main() {
-	if len(os.Args) != 2 {
-		log.Fatalf("Got unexpected number of arguments %d\n", len(os.Args)-1)
+// Struct column is a named rating to show.
+type column struct {
+	Name string
+	Rate perception.Rating
+}
+
+// Struct score is the ratings of one file, in the order of the columns.
+type score struct {
+	Path    string
+	Ratings []float64
+	Image   image.Image
+}
+
+// personalityColumns are the ratings of each color, the compositions and the
+// final rating of a personality.
+func personalityColumns(pers *perception.Personality) []column {
+	var cols []column
+	for i, ideal := range pers.Interests {
+		cols = append(cols, column{palettes.PICO8_NAMES[i], perception.NewRating(ideal, palettes.PICO8[i])})
+	}
+	var names []string
+	for n := range pers.Weights {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	if len(names) > 1 {
+		for _, n := range names {
+			if n == perception.ColorsWeight {
+				cols = append(cols, column{n, pers.Interests.Rate})
+			} else {
+				cols = append(cols, column{n, perception.Compositions[n]})
+			}
+		}
+	}
+	return append(cols, column{"final", pers.Rate})
+}
+
+func namedColumns(names []string) ([]column, error) {
+	var cols []column
+	for _, n := range names {
+		r, ok := perception.Ratings[n]
+		if !ok {
+			return nil, fmt.Errorf("Unexpected rating %q", n)
+		}
+		cols = append(cols, column{n, r})
+	}
+	return cols, nil
+}
+
+// cvdColumns are the ratings for each color vision deficiency, then the
+// worst of them.
+func cvdColumns() []column {
+	var cols []column
+	for _, d := range perception.Deficiencies {
+		cols = append(cols, column{"cvd-" + d.String(), perception.NewDeficiencyRating(d)})
+	}
+	return append(cols, column{"accessible", perception.RateAccessible})
+}
+
+// colorName names a PICO-8 color, or formats other colors as hex.
+func colorName(c color.Color) string {
+	for i, pc := range palettes.PICO8 {
+		r1, g1, b1, a1 := c.RGBA()
+		r2, g2, b2, a2 := pc.RGBA()
+		if r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2 {
+			return palettes.PICO8_NAMES[i]
+		}
+	}
+	return palettes.Hex(c)
+}
+
+// writeConfused lists the neighboring colors each deficiency confuses.
+func writeConfused(im image.Image) {
+	for _, d := range perception.Deficiencies {
+		for _, p := range perception.ConfusedPairs(im, d) {
+			fmt.Printf("%s confuses %s and %s: %d edges\n", d, colorName(p.A), colorName(p.B), p.Edges)
+		}
+	}
+}
+
+func loadSpec(p string) ([]string, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", p, err)
+	}
+	var names []string
+	if err := json.Unmarshal(b, &names); err != nil {
+		return nil, fmt.Errorf("Error decoding %s: %s", p, err)
+	}
+	return names, nil
+}
+
+// expand returns the files matching each argument.
+func expand(args []string) ([]string, error) {
+	var paths []string
+	for _, a := range args {
+		ms, err := filepath.Glob(a)
+		if err != nil {
+			return nil, fmt.Errorf("Bad pattern %q: %s", a, err)
+		}
+		if len(ms) == 0 {
+			return nil, fmt.Errorf("No files match %q", a)
+		}
+		paths = append(paths, ms...)
 	}
-	p := os.Args[1]
+	return paths, nil
+}
+
+// readImage decodes a PNG file. If quantize is true, its colors are mapped
+// to the PICO-8 palette.
+func readImage(p string, quantize bool) (image.Image, error) {
 	f, err := os.Open(p)
 	if err != nil {
-		log.Fatalf("Error opening %s: %s", p, err)
+		return nil, fmt.Errorf("Error opening %s: %s", p, err)
 	}
 	defer f.Close()
 	im, err := png.Decode(f)
 	if err != nil {
-		log.Fatalf("Error decoding %s: %s", p, err)
-	}
-
-	b := im.Bounds()
-	w := b.Max.X - b.Min.X
-	h := b.Max.Y - b.Min.Y
-	pxls := w * h
-	cnts := perception.CountColors(im)
-	rt := 0.0
-	// Interests were randomly generated using:
-	// for i := 0; i < 16; i++ {
-	// 	fmt.Printf("%f\n", rand.Float32())
-	// }
-	r := perception.RateImage(pxls, cnts[palettes.PICO8_BLACK], 0.604660)
-	fmt.Printf("black: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_DARK_BLUE], 0.940509)
-	fmt.Printf("dark-blue: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_DARK_PURPLE], 0.664560)
-	fmt.Printf("dark-purple: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_DARK_GREEN], 0.437714)
-	fmt.Printf("dark-green: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_BROWN], 0.424637)
-	fmt.Printf("brown: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_DARK_GRAY], 0.686823)
-	fmt.Printf("dark-gray: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_LIGHT_GRAY], 0.065637)
-	fmt.Printf("light-gray: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_WHITE], 0.156519)
-	fmt.Printf("white: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_RED], 0.096970)
-	fmt.Printf("red: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_ORANGE], 0.300912)
-	fmt.Printf("orange: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_YELLOW], 0.515213)
-	fmt.Printf("yellow: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_GREEN], 0.813640)
-	fmt.Printf("green: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_BLUE], 0.214264)
-	fmt.Printf("blue: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_INDIGO], 0.380657)
-	fmt.Printf("indigo: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_PINK], 0.318058)
-	fmt.Printf("pink: %f\n", r)
-	rt += r
-	r = perception.RateImage(pxls, cnts[palettes.PICO8_PEACH], 0.468890)
-	fmt.Printf("peach: %f\n", r)
-	rt += r
-	rt /= 16.0
-	fmt.Printf("\nfinal: %f\n", rt)
+		return nil, fmt.Errorf("Error decoding %s: %s", p, err)
+	}
+	if !quantize {
+		return im, nil
+	}
+	pim, off := perception.ToPalette(im, palettes.PICO8)
+	if off > 0 {
+		log.Printf("warning: %s: mapped %d pixels not in the palette to the nearest color\n", p, off)
+	}
+	return pim, nil
+}
+
+func writeText(cols []column, scores []score, cvd bool) {
+	for i, s := range scores {
+		if len(scores) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s\n", s.Path)
+		}
+		for j, c := range cols {
+			fmt.Printf("%s: %f\n", c.Name, s.Ratings[j])
+		}
+		if cvd {
+			writeConfused(s.Image)
+		}
+	}
+}
+
+func writeRank(by int, scores []score) {
+	for i, s := range scores {
+		fmt.Printf("%d. %f %s\n", i+1, s.Ratings[by], s.Path)
+	}
+}
+
+func writeJSON(cols []column, scores []score) error {
+	type result struct {
+		Path    string             `json:"path"`
+		Ratings map[string]float64 `json:"ratings"`
+	}
+	var out []result
+	for _, s := range scores {
+		r := result{Path: s.Path, Ratings: make(map[string]float64)}
+		for j, c := range cols {
+			r.Ratings[c.Name] = s.Ratings[j]
+		}
+		out = append(out, r)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func writeCSV(cols []column, scores []score) error {
+	w := csv.NewWriter(os.Stdout)
+	header := []string{"path"}
+	for _, c := range cols {
+		header = append(header, c.Name)
+	}
+	w.Write(header)
+	for _, s := range scores {
+		row := []string{s.Path}
+		for _, r := range s.Ratings {
+			row = append(row, strconv.FormatFloat(r, 'f', -1, 64))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func main() {
+	var ap string
+	var rns string
+	var sp string
+	var format string
+	var rank bool
+	var by string
+	var cvd bool
+	flag.StringVar(&ap, "artist", "", "Path to a personality profile to rate with.")
+	flag.StringVar(&rns, "ratings", "", "Comma-separated names of ratings to show instead of the personality's.")
+	flag.StringVar(&sp, "spec", "", "Path to a JSON list of names of ratings to show instead of the personality's.")
+	flag.StringVar(&format, "format", "text", "Output format: text|json|csv")
+	flag.BoolVar(&rank, "rank", false, "Sort files from the highest to lowest score.")
+	flag.StringVar(&by, "by", "", "Name of the rating to rank by. Default is final, or the first rating.")
+	flag.BoolVar(&cvd, "cvd", false, "Show how well files read with color vision deficiencies, in their own colors, instead of the personality's ratings.")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("Expected at least one PNG file or glob.")
+	}
+
+	pers := perception.DefaultPersonality()
+	if ap != "" {
+		var err error
+		pers, err = perception.LoadPersonality(ap)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var names []string
+	for _, n := range strings.Split(rns, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	if sp != "" {
+		ns, err := loadSpec(sp)
+		if err != nil {
+			log.Fatal(err)
+		}
+		names = append(names, ns...)
+	}
+	if cvd && len(names) > 0 {
+		log.Fatal("Expected either -cvd or -ratings and -spec, not both.")
+	}
+	cols := personalityColumns(pers)
+	if cvd {
+		cols = cvdColumns()
+	}
+	if len(names) > 0 {
+		var err error
+		cols, err = namedColumns(names)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	byCol := -1
+	if by == "" {
+		byCol = 0
+		by = "final"
+	}
+	for j, c := range cols {
+		if c.Name == by {
+			byCol = j
+		}
+	}
+	if byCol < 0 {
+		log.Fatalf("Unexpected value for by: %q", by)
+	}
+
+	paths, err := expand(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	var scores []score
+	for _, p := range paths {
+		// Colorblind viewers see the colors the file actually has.
+		im, err := readImage(p, !cvd)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s := score{Path: p, Image: im}
+		for _, c := range cols {
+			s.Ratings = append(s.Ratings, c.Rate(im))
+		}
+		scores = append(scores, s)
+	}
+	if rank {
+		sort.SliceStable(scores, func(i, j int) bool {
+			return scores[i].Ratings[byCol] > scores[j].Ratings[byCol]
+		})
+	}
+
+	switch format {
+	case "text":
+		if rank {
+			writeRank(byCol, scores)
+		} else {
+			writeText(cols, scores, cvd)
+		}
+	case "json":
+		err = writeJSON(cols, scores)
+	case "csv":
+		err = writeCSV(cols, scores)
+	default:
+		log.Fatalf("Unexpected value for format: %q", format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 }
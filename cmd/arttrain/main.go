@@ -0,0 +1,77 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"log"
+	"math/rand"
+	"os"
+
+	"github.com/tswast/pixelsketches/village/perception"
+	"github.com/tswast/pixelsketches/village/strategy"
+)
+
+func main() {
+	var seed int
+	var episodes int
+	var steps int
+	var alpha float64
+	var gamma float64
+	var epsilon float64
+	var rn string
+	var ap string
+	var wp string
+	flag.IntVar(&seed, "seed", 19700101, "Seed used for random number generator.")
+	flag.IntVar(&episodes, "episodes", 100, "Number of training episodes.")
+	flag.IntVar(&steps, "steps", 5000, "Maximum number of actions per episode.")
+	flag.Float64Var(&alpha, "alpha", 0.01, "Learning rate.")
+	flag.Float64Var(&gamma, "gamma", 0.95, "Discount of future rewards.")
+	flag.Float64Var(&epsilon, "epsilon", 0.1, "Probability of exploring a random action.")
+	flag.StringVar(&rn, "rating", "whole", "Name of the rating to learn.")
+	flag.StringVar(&ap, "artist", "", "Path to a personality profile to rate with instead of -rating.")
+	flag.StringVar(&wp, "weights", "", "Path to the weights file. Training continues from it if it exists.")
+	flag.Parse()
+	if wp == "" {
+		log.Fatal("Value for -weights is missing.")
+	}
+
+	rating, ok := perception.Ratings[rn]
+	if ap != "" {
+		pers, err := perception.LoadPersonality(ap)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rating = pers.Rate
+	} else if !ok {
+		log.Fatalf("Unexpected value for rating: %q", rn)
+	}
+
+	q := strategy.NewQLearner(rating)
+	if _, err := os.Stat(wp); err == nil {
+		q, err = strategy.LoadQLearner(wp, rating)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("continuing from %s\n", wp)
+	}
+	q.Alpha = alpha
+	q.Gamma = gamma
+	q.Epsilon = epsilon
+
+	rng := rand.New(rand.NewSource(int64(seed)))
+	for ep := 0; ep < episodes; ep++ {
+		rt := q.Episode(steps, rng)
+		log.Printf("episode: %d rating: %f\n", ep, rt)
+		if ep%10 == 9 {
+			if err := q.Save(wp); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if err := q.Save(wp); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village"
+	"github.com/tswast/pixelsketches/village/perception"
+	"github.com/tswast/pixelsketches/village/strategy"
+)
+
+func main() {
+	var seed int
+	var maxIter int
+	var tl bool
+	var p string
+	var sc string
+	var slice int
+	var n int
+	var pers bool
+	flag.IntVar(&seed, "seed", 19700101, "Seed used for random number generator.")
+	flag.IntVar(&maxIter, "max-iter", 1000000, "Maximum number of iterations.")
+	flag.BoolVar(&tl, "timelapse", false, "Write timelapse to out/ directory.")
+	flag.StringVar(&p, "out", "", "Path to output file.")
+	flag.StringVar(&sc, "schedule", "round-robin", "Scheduler to use: round-robin|simultaneous|time-sliced")
+	flag.IntVar(&slice, "slice", 50, "Frames per turn for the time-sliced scheduler.")
+	flag.IntVar(&n, "artists", 4, "Number of artists. Each one is interested in a single color.")
+	flag.BoolVar(&pers, "personalities", false, "Give each artist a personality generated from the seed instead.")
+	flag.Parse()
+	if p == "" {
+		log.Fatal("Value for -out is missing.")
+	}
+
+	var sched village.Scheduler
+	if sc == "round-robin" {
+		sched = &village.RoundRobin{}
+	} else if sc == "simultaneous" {
+		sched = &village.Simultaneous{}
+	} else if sc == "time-sliced" {
+		sched = &village.TimeSliced{Slice: slice}
+	} else {
+		log.Fatal("Unexpected value for schedule.")
+	}
+
+	v := village.New(sched)
+	for i := 0; i < n; i++ {
+		if pers {
+			pr := perception.NewPersonality(int64(seed + i))
+			v.AddArtist(fmt.Sprintf("artist-%d", seed+i), &strategy.Ideal{Rating: pr.Rate}, pr.Rate)
+			continue
+		}
+		c := i % len(palettes.PICO8)
+		r := perception.NewRating(perception.DefaultInterests[c], palettes.PICO8[c])
+		v.AddArtist(fmt.Sprintf("%s-%d", palettes.PICO8_NAMES[c], i), &strategy.Ideal{Rating: r}, r)
+	}
+
+	if err := village.Main(p, int64(seed), tl, maxIter, v); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -8,6 +8,7 @@
 package palettes
 
 import (
+	"fmt"
 	"image/color"
 )
 
@@ -54,3 +55,32 @@ var PICO8 = []color.Color{
 	PICO8_PINK,
 	PICO8_PEACH,
 }
+
+// Names of the colors in the PICO8 palette, in the same order.
+var PICO8_NAMES = []string{
+	"black",
+	"dark-blue",
+	"dark-purple",
+	"dark-green",
+	"brown",
+	"dark-gray",
+	"light-gray",
+	"white",
+	"red",
+	"orange",
+	"yellow",
+	"green",
+	"blue",
+	"indigo",
+	"pink",
+	"peach",
+}
+
+// Hex formats a color as a hex triplet, such as "#ff004d".
+func Hex(c color.Color) string {
+	if c == nil {
+		return ""
+	}
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
@@ -0,0 +1,551 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package pico8 reads and writes PICO-8 text cartridges, .p8 files.
+//
+// A cartridge is a header followed by sections, each starting with a line
+// such as __gfx__. The sprite sheet and label are *image.Paletted with the
+// palettes.PICO8 palette, so that pictures the bots draw can be moved into
+// PICO-8 and back.
+//
+// See: http://pico-8.wikia.com/wiki/P8FileFormat
+package pico8
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+const (
+	// ImageSize is the width and height of the sprite sheet and the label.
+	ImageSize = 128
+	// SpriteSize is the width and height of a sprite.
+	SpriteSize = 8
+	// Sprites is the number of sprites in the sprite sheet, each with flags.
+	Sprites = (ImageSize / SpriteSize) * (ImageSize / SpriteSize)
+	// MapWidth and MapHeight are the size in tiles of the map in the
+	// __map__ section. The rest of the map shares memory with the bottom of
+	// the sprite sheet.
+	MapWidth  = 128
+	MapHeight = 32
+	// SFXCount is the number of sound effects, and Notes the number of
+	// notes in each.
+	SFXCount = 64
+	Notes    = 32
+	// PatternCount is the number of music patterns, and Channels the
+	// number of sound effects each plays at once.
+	PatternCount = 64
+	Channels     = 4
+)
+
+// Version is the cartridge format version which Encode writes by default.
+const Version = 8
+
+const header = "pico-8 cartridge // http://www.pico-8.com"
+
+// Struct Note is one note of a sound effect.
+type Note struct {
+	Pitch    uint8
+	Waveform uint8
+	Volume   uint8
+	Effect   uint8
+}
+
+// Struct SFX is a sound effect.
+type SFX struct {
+	// Editor is the mode the sound editor shows the effect in.
+	Editor    uint8
+	Speed     uint8
+	LoopStart uint8
+	LoopEnd   uint8
+	Notes     [Notes]Note
+}
+
+// Struct Pattern is a music pattern, the sound effects played together on
+// each channel.
+type Pattern struct {
+	// Flags are the loop start, loop end and stop flags.
+	Flags uint8
+	// Channels are the sound effect of each channel. Bit 6 set means the
+	// channel is silent.
+	Channels [Channels]uint8
+}
+
+// Struct Cart is a PICO-8 cartridge.
+//
+// A nil field is a section the cartridge doesn't have. Sections which are
+// shorter, in the file or in the Cart, are filled with zeros. Sections this
+// package doesn't know, such as __meta:title__ in newer cartridges, are
+// skipped.
+type Cart struct {
+	// Version is the format version from the header.
+	Version int
+	// Lua is the source code, ending in a newline.
+	Lua string
+	// Gfx is the sprite sheet.
+	Gfx *image.Paletted
+	// Flags are the flags of each sprite.
+	Flags []uint8
+	// Label is the picture shown for the cartridge.
+	Label *image.Paletted
+	// Map are the tiles of the map, row by row.
+	Map []uint8
+	SFX []SFX
+	// Music are the music patterns.
+	Music []Pattern
+}
+
+// NewCart creates an empty cartridge, with a blank sprite sheet and label.
+func NewCart() *Cart {
+	return &Cart{
+		Version: Version,
+		Gfx:     NewImage(),
+		Label:   NewImage(),
+	}
+}
+
+// NewImage creates a black image the size of the sprite sheet and label.
+func NewImage() *image.Paletted {
+	return image.NewPaletted(image.Rect(0, 0, ImageSize, ImageSize), palettes.PICO8)
+}
+
+// ToImage converts an image to a sprite sheet or label, with its top left
+// corner at the top left. Colors become the closest PICO-8 color, and the
+// parts outside of ImageSize are cut off.
+func ToImage(im image.Image) *image.Paletted {
+	p := NewImage()
+	draw.Draw(p, p.Bounds(), im, im.Bounds().Min, draw.Src)
+	return p
+}
+
+// Sprite returns sprite n of a sprite sheet. It shares the sheet's pixels.
+func Sprite(sheet *image.Paletted, n int) *image.Paletted {
+	perRow := ImageSize / SpriteSize
+	x, y := (n%perRow)*SpriteSize, (n/perRow)*SpriteSize
+	r := image.Rect(x, y, x+SpriteSize, y+SpriteSize).Add(sheet.Rect.Min)
+	return sheet.SubImage(r).(*image.Paletted)
+}
+
+// hexDigits parses each character of s as a hexadecimal digit.
+func hexDigits(s string) ([]uint8, error) {
+	ds := make([]uint8, len(s))
+	for i := 0; i < len(s); i++ {
+		d, err := strconv.ParseUint(s[i:i+1], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("bad hex digit %q", s[i])
+		}
+		ds[i] = uint8(d)
+	}
+	return ds, nil
+}
+
+// hexBytes parses each pair of characters of s as a hexadecimal byte.
+func hexBytes(s string) ([]uint8, error) {
+	if len(s)%2 != 0 {
+		return nil, fmt.Errorf("odd number of hex digits")
+	}
+	ds, err := hexDigits(s)
+	if err != nil {
+		return nil, err
+	}
+	bs := make([]uint8, len(s)/2)
+	for i := range bs {
+		bs[i] = ds[2*i]<<4 | ds[2*i+1]
+	}
+	return bs, nil
+}
+
+// Struct decoder parses the sections of a cartridge.
+type decoder struct {
+	cart *Cart
+	// row is the line number within the current section, not counting blank
+	// lines.
+	row int
+	lua []string
+}
+
+func (d *decoder) startSection(name string) {
+	d.row = 0
+	c := d.cart
+	switch name {
+	case "lua":
+		d.lua = []string{}
+	case "gfx":
+		c.Gfx = NewImage()
+	case "gff":
+		c.Flags = make([]uint8, Sprites)
+	case "label":
+		c.Label = NewImage()
+	case "map":
+		c.Map = make([]uint8, MapWidth*MapHeight)
+	case "sfx":
+		c.SFX = make([]SFX, SFXCount)
+	case "music":
+		c.Music = make([]Pattern, PatternCount)
+	}
+}
+
+// extendedColors are the colors of PICO-8's extended palette, which labels
+// of newer cartridges write as the digits g to v.
+var extendedColors = []color.Color{
+	color.RGBA{41, 24, 20, 255},
+	color.RGBA{17, 29, 53, 255},
+	color.RGBA{66, 33, 54, 255},
+	color.RGBA{18, 83, 89, 255},
+	color.RGBA{116, 47, 41, 255},
+	color.RGBA{73, 51, 59, 255},
+	color.RGBA{162, 136, 121, 255},
+	color.RGBA{243, 239, 125, 255},
+	color.RGBA{190, 18, 80, 255},
+	color.RGBA{255, 108, 36, 255},
+	color.RGBA{168, 231, 46, 255},
+	color.RGBA{0, 181, 67, 255},
+	color.RGBA{6, 90, 181, 255},
+	color.RGBA{117, 70, 101, 255},
+	color.RGBA{255, 110, 89, 255},
+	color.RGBA{255, 157, 129, 255},
+}
+
+// labelDigits parses each character of s as a color of a label. The digits
+// g to v of the extended palette become the closest PICO-8 color, since
+// labels use palettes.PICO8.
+func labelDigits(s string) ([]uint8, error) {
+	ds := make([]uint8, len(s))
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 'g' && ch <= 'v' {
+			ds[i] = uint8(color.Palette(palettes.PICO8).Index(extendedColors[ch-'g']))
+			continue
+		}
+		d, err := hexDigits(s[i : i+1])
+		if err != nil {
+			return nil, err
+		}
+		ds[i] = d[0]
+	}
+	return ds, nil
+}
+
+// decodeImageRow parses a row of digits, one per pixel.
+func decodeImageRow(im *image.Paletted, y int, line string, digits func(string) ([]uint8, error)) error {
+	if y >= ImageSize {
+		return fmt.Errorf("more than %d rows", ImageSize)
+	}
+	if len(line) > ImageSize {
+		return fmt.Errorf("more than %d pixels", ImageSize)
+	}
+	ds, err := digits(line)
+	if err != nil {
+		return err
+	}
+	copy(im.Pix[im.PixOffset(0, y):], ds)
+	return nil
+}
+
+// decodeBytes parses a line of hex bytes into a row of bs, width bytes wide.
+func decodeBytes(bs []uint8, row, width int, line string) error {
+	if (row+1)*width > len(bs) {
+		return fmt.Errorf("more than %d rows", len(bs)/width)
+	}
+	b, err := hexBytes(line)
+	if err != nil {
+		return err
+	}
+	if len(b) > width {
+		return fmt.Errorf("more than %d bytes", width)
+	}
+	copy(bs[row*width:], b)
+	return nil
+}
+
+func decodeSFX(s *SFX, line string) error {
+	if len(line) != 8+5*Notes {
+		return fmt.Errorf("sfx is %d digits, want %d", len(line), 8+5*Notes)
+	}
+	h, err := hexBytes(line[:8])
+	if err != nil {
+		return err
+	}
+	s.Editor, s.Speed, s.LoopStart, s.LoopEnd = h[0], h[1], h[2], h[3]
+	for i := range s.Notes {
+		n := line[8+5*i : 8+5*(i+1)]
+		p, err := hexBytes(n[:2])
+		if err != nil {
+			return err
+		}
+		ds, err := hexDigits(n[2:])
+		if err != nil {
+			return err
+		}
+		s.Notes[i] = Note{Pitch: p[0], Waveform: ds[0], Volume: ds[1], Effect: ds[2]}
+	}
+	return nil
+}
+
+func decodePattern(p *Pattern, line string) error {
+	fs := strings.Fields(line)
+	if len(fs) != 2 || len(fs[0]) != 2 || len(fs[1]) != 2*Channels {
+		return fmt.Errorf("pattern is %q, want flags and %d channels", line, Channels)
+	}
+	f, err := hexBytes(fs[0])
+	if err != nil {
+		return err
+	}
+	chs, err := hexBytes(fs[1])
+	if err != nil {
+		return err
+	}
+	p.Flags = f[0]
+	copy(p.Channels[:], chs)
+	return nil
+}
+
+// line parses a line of the current section.
+func (d *decoder) line(section, line string) error {
+	c := d.cart
+	if section == "lua" {
+		d.lua = append(d.lua, line)
+		return nil
+	}
+	// Data sections may be followed by a blank line.
+	if line == "" {
+		return nil
+	}
+	row := d.row
+	d.row++
+	switch section {
+	case "gfx":
+		return decodeImageRow(c.Gfx, row, line, hexDigits)
+	case "label":
+		return decodeImageRow(c.Label, row, line, labelDigits)
+	case "gff":
+		return decodeBytes(c.Flags, row, Sprites/2, line)
+	case "map":
+		return decodeBytes(c.Map, row, MapWidth, line)
+	case "sfx":
+		if row >= SFXCount {
+			return fmt.Errorf("more than %d sfx", SFXCount)
+		}
+		return decodeSFX(&c.SFX[row], line)
+	case "music":
+		if row >= PatternCount {
+			return fmt.Errorf("more than %d patterns", PatternCount)
+		}
+		return decodePattern(&c.Music[row], line)
+	}
+	// Skip sections this package doesn't know.
+	return nil
+}
+
+// sectionName returns the name of a section's first line, such as "gfx" for
+// __gfx__ or "meta:title" for __meta:title__, or "" if it isn't one.
+func sectionName(line string) string {
+	if len(line) > 4 && strings.HasPrefix(line, "__") && strings.HasSuffix(line, "__") {
+		name := line[2 : len(line)-2]
+		if strings.IndexFunc(name, unicode.IsSpace) < 0 {
+			return name
+		}
+	}
+	return ""
+}
+
+// Decode reads a cartridge.
+func Decode(r io.Reader) (*Cart, error) {
+	sc := bufio.NewScanner(r)
+	n := 0
+	next := func() (string, bool) {
+		if !sc.Scan() {
+			return "", false
+		}
+		n++
+		return strings.TrimSuffix(sc.Text(), "\r"), true
+	}
+	if l, ok := next(); !ok || !strings.HasPrefix(l, "pico-8 cartridge") {
+		return nil, fmt.Errorf("Error on line 1: not a pico-8 cartridge")
+	}
+	c := &Cart{}
+	l, ok := next()
+	if !ok || !strings.HasPrefix(l, "version ") {
+		return nil, fmt.Errorf("Error on line 2: missing version")
+	}
+	v, err := strconv.Atoi(strings.TrimPrefix(l, "version "))
+	if err != nil {
+		return nil, fmt.Errorf("Error on line 2: bad version %q", l)
+	}
+	c.Version = v
+
+	d := &decoder{cart: c}
+	section := ""
+	for {
+		l, ok := next()
+		if !ok {
+			break
+		}
+		if name := sectionName(l); name != "" {
+			section = name
+			d.startSection(name)
+			continue
+		}
+		if section == "" {
+			// PICO-8 ignores anything before the first section.
+			continue
+		}
+		if err := d.line(section, l); err != nil {
+			return nil, fmt.Errorf("Error on line %d in __%s__: %s", n, section, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading cartridge: %s", err)
+	}
+	if d.lua != nil {
+		c.Lua = strings.Join(d.lua, "\n")
+		if len(d.lua) > 0 {
+			c.Lua += "\n"
+		}
+	}
+	return c, nil
+}
+
+// Load reads a cartridge from a .p8 file.
+func Load(path string) (*Cart, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", path, err)
+	}
+	defer f.Close()
+	c, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding %s: %s", path, err)
+	}
+	return c, nil
+}
+
+// Struct encoder writes the sections of a cartridge, keeping the first error.
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (e *encoder) printf(format string, args ...interface{}) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
+
+func (e *encoder) image(im *image.Paletted) {
+	const digits = "0123456789abcdef"
+	b := im.Bounds()
+	line := make([]byte, ImageSize)
+	for y := 0; y < ImageSize; y++ {
+		for x := 0; x < ImageSize; x++ {
+			c := uint8(0)
+			if image.Pt(b.Min.X+x, b.Min.Y+y).In(b) {
+				c = im.ColorIndexAt(b.Min.X+x, b.Min.Y+y)
+			}
+			line[x] = digits[c&0xf]
+		}
+		e.printf("%s\n", line)
+	}
+}
+
+// bytes writes bs in rows of width bytes, padded with zeros to at least size
+// bytes and to a whole number of rows.
+func (e *encoder) bytes(bs []uint8, width, size int) {
+	if len(bs) > size {
+		size = len(bs)
+	}
+	row := make([]uint8, width)
+	for i := 0; i < size; i += width {
+		n := 0
+		if i < len(bs) {
+			n = copy(row, bs[i:])
+		}
+		for j := n; j < width; j++ {
+			row[j] = 0
+		}
+		e.printf("%x\n", row)
+	}
+}
+
+// Encode writes a cartridge. Each section it has is written in full, in the
+// order PICO-8 writes them.
+func (c *Cart) Encode(w io.Writer) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+	v := c.Version
+	if v == 0 {
+		v = Version
+	}
+	e.printf("%s\nversion %d\n", header, v)
+	e.printf("__lua__\n%s", c.Lua)
+	if c.Lua != "" && !strings.HasSuffix(c.Lua, "\n") {
+		e.printf("\n")
+	}
+	if c.Gfx != nil {
+		e.printf("__gfx__\n")
+		e.image(c.Gfx)
+	}
+	if c.Label != nil {
+		e.printf("__label__\n")
+		e.image(c.Label)
+	}
+	// PICO-8 leaves a blank line after the pictures.
+	if c.Gfx != nil || c.Label != nil {
+		e.printf("\n")
+	}
+	if c.Flags != nil {
+		e.printf("__gff__\n")
+		e.bytes(c.Flags, Sprites/2, Sprites)
+	}
+	if c.Map != nil {
+		e.printf("__map__\n")
+		e.bytes(c.Map, MapWidth, MapWidth*MapHeight)
+	}
+	if c.SFX != nil {
+		e.printf("__sfx__\n")
+		for _, s := range c.SFX {
+			e.printf("%02x%02x%02x%02x", s.Editor, s.Speed, s.LoopStart, s.LoopEnd)
+			for _, n := range s.Notes {
+				e.printf("%02x%x%x%x", n.Pitch, n.Waveform&0xf, n.Volume&0xf, n.Effect&0xf)
+			}
+			e.printf("\n")
+		}
+	}
+	if c.Music != nil {
+		e.printf("__music__\n")
+		for _, p := range c.Music {
+			e.printf("%02x %x\n", p.Flags, p.Channels[:])
+		}
+		e.printf("\n")
+	}
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.err
+}
+
+// Save writes a cartridge to a .p8 file.
+func (c *Cart) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %s", path, err)
+	}
+	if err := c.Encode(f); err != nil {
+		f.Close()
+		return fmt.Errorf("Error encoding %s: %s", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("Error writing %s: %s", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package pico8
+
+import (
+	"bytes"
+	"image"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+// carts are the cartridges shipped with the repo.
+func carts(t *testing.T) []string {
+	paths, err := filepath.Glob("../pico-8/*.p8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no cartridges found in ../pico-8")
+	}
+	return paths
+}
+
+func TestLoad(t *testing.T) {
+	c, err := Load("../pico-8/doodle001.p8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 8 {
+		t.Errorf("Version => %d, want 8", c.Version)
+	}
+	if !strings.HasPrefix(c.Lua, "-- doodle #1\n") || !strings.HasSuffix(c.Lua, "\nend\n") {
+		t.Errorf("Lua => %q..., want the code of doodle #1", c.Lua[:20])
+	}
+	// The first label row has blue at x = 32.
+	if got := c.Label.At(32, 0); got != palettes.PICO8_BLUE {
+		t.Errorf("Label.At(32, 0) => %v, want blue", got)
+	}
+	if c.SFX[0].Speed != 1 {
+		t.Errorf("SFX[0].Speed => %d, want 1", c.SFX[0].Speed)
+	}
+	want := Pattern{Flags: 0, Channels: [Channels]uint8{0x41, 0x42, 0x43, 0x44}}
+	if c.Music[0] != want {
+		t.Errorf("Music[0] => %v, want %v", c.Music[0], want)
+	}
+
+	c, err = Load("../pico-8/numbers.p8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Label != nil {
+		t.Errorf("Label => not nil, want nil for a cartridge without a label")
+	}
+	if got := c.Flags[:4]; !reflect.DeepEqual(got, []uint8{0, 0, 1, 1}) {
+		t.Errorf("Flags[:4] => %v, want [0 0 1 1]", got)
+	}
+
+	// PICO-8 leaves blank rows off the end of the sprite sheet.
+	c, err = Load("../pico-8/gamekitty.p8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Gfx.ColorIndexAt(1, 55) == 0 {
+		t.Errorf("Gfx.ColorIndexAt(1, 55) => 0, want the last row in the file")
+	}
+	for y := 56; y < ImageSize; y++ {
+		for x := 0; x < ImageSize; x++ {
+			if c.Gfx.ColorIndexAt(x, y) != 0 {
+				t.Fatalf("Gfx.ColorIndexAt(%d, %d) => %d, want 0", x, y, c.Gfx.ColorIndexAt(x, y))
+			}
+		}
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, path := range carts(t) {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			continue
+		}
+		var out bytes.Buffer
+		if err := c.Encode(&out); err != nil {
+			t.Errorf("%s: %s", path, err)
+			continue
+		}
+		// Version 8 cartridges have every section in full, so they are
+		// written exactly as they were read.
+		if c.Version == 8 && !bytes.Equal(out.Bytes(), b) {
+			t.Errorf("%s: encoded cartridge differs from the file", path)
+		}
+		got, err := Decode(&out)
+		if err != nil {
+			t.Errorf("%s: decoding encoded cartridge: %s", path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c) {
+			t.Errorf("%s: decoded cartridge differs after encoding", path)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	const hdr = "pico-8 cartridge // http://www.pico-8.com\nversion 8\n"
+	for _, tc := range []struct {
+		name, in, want string
+	}{
+		{"empty", "", "line 1"},
+		{"not-a-cart", "hello\n", "line 1"},
+		{"no-version", "pico-8 cartridge // http://www.pico-8.com\n", "line 2"},
+		{"bad-version", "pico-8 cartridge // http://www.pico-8.com\nversion x\n", "line 2"},
+		{"bad-gfx", hdr + "__gfx__\n00z0\n", "line 4 in __gfx__"},
+		{"long-gfx", hdr + "__gfx__\n" + strings.Repeat("0", ImageSize+1) + "\n", "line 4"},
+		{"tall-label", hdr + "__label__\n" + strings.Repeat("0\n", ImageSize+1), "line 132"},
+		{"odd-map", hdr + "__map__\n000\n", "line 4 in __map__"},
+		{"short-sfx", hdr + "__sfx__\n0001\n", "line 4 in __sfx__"},
+		{"bad-music", hdr + "__music__\n00 414243\n", "line 4 in __music__"},
+	} {
+		_, err := Decode(strings.NewReader(tc.in))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: Decode => %v, want error containing %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+// TestDecodeNewer checks that cartridges from newer versions of PICO-8
+// decode.
+func TestDecodeNewer(t *testing.T) {
+	in := "pico-8 cartridge // http://www.pico-8.com\nversion 41\n" +
+		"__lua__\nprint(1)\n" +
+		"__label__\n07gv\n\n" +
+		"__meta:title__\nmy cart\nby me\n" +
+		"__music__\n00 41424344\n"
+	c, err := Decode(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Lua != "print(1)\n" {
+		t.Errorf("Lua => %q, want \"print(1)\\n\"", c.Lua)
+	}
+	// g, the extended dark brown, is closest to black, and v to pink.
+	want := []uint8{0, 7, 0, 14}
+	if got := c.Label.Pix[:4]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Label.Pix[:4] => %v, want %v", got, want)
+	}
+	if c.Music[0].Channels[0] != 0x41 {
+		t.Errorf("Music[0].Channels[0] => %#x, want 0x41", c.Music[0].Channels[0])
+	}
+}
+
+// TestEncodeShort checks that sections shorter than PICO-8's are padded.
+func TestEncodeShort(t *testing.T) {
+	c := &Cart{Flags: []uint8{1, 2, 3}, Map: make([]uint8, 200)}
+	c.Map[199] = 9
+	var out bytes.Buffer
+	if err := c.Encode(&out); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Flags) != Sprites || !reflect.DeepEqual(got.Flags[:4], []uint8{1, 2, 3, 0}) {
+		t.Errorf("Flags => %v..., want [1 2 3 0] padded to %d", got.Flags[:4], Sprites)
+	}
+	if len(got.Map) != MapWidth*MapHeight || got.Map[199] != 9 || got.Map[200] != 0 {
+		t.Errorf("Map => %d tiles, with %d at 199, want %d tiles, with 9 at 199", len(got.Map), got.Map[199], MapWidth*MapHeight)
+	}
+}
+
+func TestToImage(t *testing.T) {
+	// A picture the size of the GUI's canvas, in PICO-8 colors.
+	im := image.NewPaletted(image.Rect(10, 20, 99, 84), palettes.PICO8)
+	im.Set(10, 20, palettes.PICO8_PINK)
+	im.Set(98, 83, palettes.PICO8_GREEN)
+	p := ToImage(im)
+	if p.Bounds() != image.Rect(0, 0, ImageSize, ImageSize) {
+		t.Fatalf("Bounds() => %v, want %dx%d", p.Bounds(), ImageSize, ImageSize)
+	}
+	if got := p.At(0, 0); got != palettes.PICO8_PINK {
+		t.Errorf("At(0, 0) => %v, want pink", got)
+	}
+	if got := p.At(88, 63); got != palettes.PICO8_GREEN {
+		t.Errorf("At(88, 63) => %v, want green", got)
+	}
+	if got := p.At(100, 100); got != palettes.PICO8_BLACK {
+		t.Errorf("At(100, 100) => %v, want black", got)
+	}
+
+	c := NewCart()
+	c.Label = p
+	var out bytes.Buffer
+	if err := c.Encode(&out); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Label.Pix, p.Pix) {
+		t.Errorf("label differs after encoding")
+	}
+}
+
+func TestSprite(t *testing.T) {
+	sheet := NewImage()
+	sheet.Set(8, 8, palettes.PICO8_RED)
+	s := Sprite(sheet, 17)
+	if s.Bounds() != image.Rect(8, 8, 16, 16) {
+		t.Errorf("Bounds() => %v, want (8,8)-(16,16)", s.Bounds())
+	}
+	if got := s.At(8, 8); got != palettes.PICO8_RED {
+		t.Errorf("At(8, 8) => %v, want red", got)
+	}
+}
@@ -7,6 +7,8 @@ package artist
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
 	"image"
 	"image/draw"
@@ -14,9 +16,14 @@ import (
 	"log"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/debugview"
 	"github.com/tswast/pixelsketches/village/gui"
 	"github.com/tswast/pixelsketches/village/strategy"
+	"github.com/tswast/pixelsketches/village/tui"
 )
 
 func tryWriteFrame(frame int, app *gui.AppState) {
@@ -34,9 +41,191 @@ func tryWriteFrame(frame int, app *gui.AppState) {
 	w.Flush()
 }
 
+func writeDebugFrame(dir string, frame int, im image.Image) {
+	p := filepath.Join(dir, fmt.Sprintf("debug-%04d.png", frame))
+	f, err := os.Create(p)
+	if err != nil {
+		log.Printf("Could not create %s %s\n", p, err)
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := png.Encode(w, im); err != nil {
+		log.Printf("Could not encode %s %s\n", p, err)
+	}
+	w.Flush()
+}
+
+func writeDebugGIF(path string, g *debugview.GIF) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %s", path, err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := g.Encode(w); err != nil {
+		return fmt.Errorf("Error encoding %s: %s", path, err)
+	}
+	return w.Flush()
+}
+
+// Struct Options configures how an artist draws.
+type Options struct {
+	// InPath is a PNG to start editing, if not empty.
+	InPath  string
+	OutPath string
+	// Seed seeds the global random source, which strategies without their
+	// own source use.
+	Seed int64
+	// NoGlobalSeed leaves the global random source alone, so that artists
+	// drawing at the same time, whose strategies each have their own
+	// source, don't reseed each other.
+	NoGlobalSeed bool
+	// Debug logs the action and rating of every frame.
+	Debug bool
+	// TimeLapse writes every frame to the out/ directory.
+	TimeLapse bool
+	MaxIter   int
+	// Budget is how long the strategy can spend choosing each action. Zero
+	// means there is no limit.
+	Budget time.Duration
+	// TracePath is a file to write a Frame to for every frame, if not empty.
+	TracePath string
+	// DebugDir is a directory to write an annotated debugview frame to for
+	// every frame, if not empty.
+	DebugDir string
+	// DebugGIF is a file to write the annotated frames to as an animation, if
+	// not empty. The frames are kept in memory, so use it with MaxIter.
+	DebugGIF string
+	// DebugScale is how much to upscale annotated frames.
+	DebugScale int
+	// TUI draws every frame to the terminal.
+	TUI bool
+	// AllowRevisits keeps drawing when the cursor returns to an old position.
+	// Bots stop there since they are likely stuck, but humans do it on purpose.
+	AllowRevisits bool
+	// Quit stops drawing before the next action when it is closed, if not nil.
+	Quit <-chan struct{}
+	// OnFrame is called with every frame before its action is applied, if
+	// not nil. It may block, for example to pause drawing.
+	OnFrame func(fr Frame, app *gui.AppState)
+}
+
+// Struct Frame is the record of one frame in a trace file.
+//
+// A trace file has one Frame encoded as JSON per line.
+type Frame struct {
+	Frame  int            `json:"frame"`
+	Cursor gui.Cursor     `json:"cursor"`
+	Color  string         `json:"color"`
+	Trace  strategy.Trace `json:"trace"`
+}
+
+// drawTUI draws the screen and the decision to the terminal.
+func drawTUI(frame int, app *gui.AppState, t strategy.Trace) {
+	reason := ""
+	if t.Rating.Reason != nil {
+		reason = t.Rating.Reason.Explain()
+	}
+	err := tui.Frame(os.Stdout, app.DrawScreen(),
+		fmt.Sprintf("frame: %d  cursor: %v  pressed: %v  color: %s", frame, app.Cursor.Pos, app.Cursor.Pressed, palettes.Hex(app.Color)),
+		fmt.Sprintf("rating: %f  dist: %d", t.Rating.Rate, t.Rating.Dist),
+		fmt.Sprintf("reason: %s", reason))
+	if err != nil {
+		log.Printf("Could not draw frame %d: %s\n", frame, err)
+	}
+}
+
+// quit returns true if the Quit channel is closed.
+func quit(opts Options) bool {
+	select {
+	case <-opts.Quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// strategize chooses the next action within the per-frame budget, or until
+// Options.Quit is closed.
+func strategize(opts Options, s strategy.Strategizer, app *gui.AppState) strategy.Trace {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if opts.Quit != nil {
+		go func() {
+			select {
+			case <-opts.Quit:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	if opts.Budget > 0 {
+		var cancelBudget context.CancelFunc
+		ctx, cancelBudget = context.WithTimeout(ctx, opts.Budget)
+		defer cancelBudget()
+	}
+	return strategy.StrategizeTrace(ctx, s, app)
+}
+
+// Reasons a drawing stopped.
+const (
+	// ExitDone means the artist clicked the exit button.
+	ExitDone = "done"
+	// ExitMaxIter means the artist reached Options.MaxIter.
+	ExitMaxIter = "max-iter"
+	// ExitRevisit means the artist returned to a position, so is likely stuck.
+	ExitRevisit = "revisit"
+	// ExitQuit means Options.Quit was closed.
+	ExitQuit = "quit"
+	// ExitError means the strategy failed, such as a strategy.Remote whose
+	// bot exited.
+	ExitError = "error"
+)
+
+// errStrategizer is a strategy which can fail, such as strategy.Remote.
+type errStrategizer interface {
+	Err() error
+}
+
+// resetStrategizer is a strategy which remembers state between frames, such as
+// strategy.QLearner, and must forget it before each drawing.
+type resetStrategizer interface {
+	Reset()
+}
+
+// Struct Result is a finished drawing.
+type Result struct {
+	Image *image.Paletted
+	// Frames is the number of frames drawn.
+	Frames int
+	// Exit is the reason the drawing stopped, such as ExitDone.
+	Exit string
+}
+
 // Main draws a picture, writes it, and exits.
-func Main(inPath, outPath string, seed int64, debug, doTimeLapse bool, maxIter int, s strategy.Strategizer) error {
-	rand.Seed(seed)
+func Main(opts Options, s strategy.Strategizer) error {
+	res, err := Run(opts, s)
+	if res != nil {
+		fmt.Printf("frames: %d\n", res.Frames)
+	}
+	return err
+}
+
+// Run draws a picture, and writes it if Options.OutPath is not empty.
+//
+// If the strategy fails, as a strategy.Remote does when its bot exits, Run
+// stops with ExitError, writes the picture so far and returns the error.
+func Run(opts Options, s strategy.Strategizer) (*Result, error) {
+	inPath := opts.InPath
+	outPath := opts.OutPath
+	debug := opts.Debug
+	if !opts.NoGlobalSeed {
+		rand.Seed(opts.Seed)
+	}
+	if rs, ok := s.(resetStrategizer); ok {
+		rs.Reset()
+	}
 
 	app := gui.NewAppState()
 	if inPath != "" {
@@ -52,25 +241,91 @@ func Main(inPath, outPath string, seed int64, debug, doTimeLapse bool, maxIter i
 		draw.Draw(app.Image, app.Image.Bounds(), im, image.ZP, draw.Src)
 	}
 
+	var trace *json.Encoder
+	if opts.TracePath != "" {
+		f, err := os.Create(opts.TracePath)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating %s: %s", opts.TracePath, err)
+		}
+		defer f.Close()
+		w := bufio.NewWriter(f)
+		defer w.Flush()
+		trace = json.NewEncoder(w)
+	}
+
+	if opts.DebugDir != "" {
+		if err := os.MkdirAll(opts.DebugDir, 0755); err != nil {
+			return nil, fmt.Errorf("Error creating %s: %s", opts.DebugDir, err)
+		}
+	}
+	scale := opts.DebugScale
+	if scale <= 0 {
+		scale = 8
+	}
+	var anim *debugview.GIF
+	if opts.DebugGIF != "" {
+		anim = &debugview.GIF{Delay: 10}
+	}
+
+	if opts.TUI {
+		fmt.Print(tui.Clear + tui.HideCursor)
+		defer fmt.Print(tui.ShowCursor)
+	}
+
 	pts := make(map[image.Point]int)
+	exit := ExitDone
+	var stratErr error
 	frame := 0
 	for ; ; frame++ {
-		if frame > maxIter {
-			log.Printf("reached max iterations %d\n", maxIter)
+		if frame > opts.MaxIter {
+			log.Printf("reached max iterations %d\n", opts.MaxIter)
+			exit = ExitMaxIter
 			break
 		}
 		if app.Mode != gui.MODE_DRAWING {
 			break
 		}
 
-		if doTimeLapse {
+		if opts.TimeLapse {
 			tryWriteFrame(frame, app)
 		}
-		if frame%100 == 0 {
+		if frame%100 == 0 && !opts.TUI {
 			log.Printf("current-frame: %d\n", frame)
 		}
 
-		a, r := s.Strategize(app)
+		t := strategize(opts, s, app)
+		a, r := t.Chosen, t.Rating
+		if quit(opts) {
+			log.Printf("quit at frame %d\n", frame)
+			exit = ExitQuit
+			break
+		}
+		if es, ok := s.(errStrategizer); ok && es.Err() != nil {
+			stratErr = fmt.Errorf("Error from strategy at frame %d: %s", frame, es.Err())
+			exit = ExitError
+			break
+		}
+		fr := Frame{Frame: frame, Cursor: app.Cursor, Color: palettes.Hex(app.Color), Trace: t}
+		if trace != nil {
+			if err := trace.Encode(&fr); err != nil {
+				log.Printf("Error writing %s: %s\n", opts.TracePath, err)
+			}
+		}
+		if opts.OnFrame != nil {
+			opts.OnFrame(fr, app)
+		}
+		if opts.TUI {
+			drawTUI(frame, app, t)
+		}
+		if opts.DebugDir != "" || anim != nil {
+			im := debugview.Render(app, t, scale)
+			if opts.DebugDir != "" {
+				writeDebugFrame(opts.DebugDir, frame, im)
+			}
+			if anim != nil {
+				anim.Add(im)
+			}
+		}
 		if debug {
 			log.Printf(
 				"frame: %d\n\tpos: %v\n\timPos: %v\n\tcolor: %v\n\taction: %v\n\trating: %s\n",
@@ -80,7 +335,7 @@ func Main(inPath, outPath string, seed int64, debug, doTimeLapse bool, maxIter i
 				app.Color,
 				a,
 				r.String())
-			if frame%100 == 0 {
+			if frame%100 == 0 && outPath != "" {
 				f, err := os.Create(outPath)
 				if err != nil {
 					log.Printf("Error creating %s: %s\n", outPath, err)
@@ -97,13 +352,31 @@ func Main(inPath, outPath string, seed int64, debug, doTimeLapse bool, maxIter i
 		dejavu, ok := pts[app.Cursor.Pos]
 		if !ok {
 			pts[app.Cursor.Pos] = frame
-		} else if frame-dejavu > 20 {
+		} else if frame-dejavu > 20 && !opts.AllowRevisits {
 			log.Printf("already been at this position")
+			exit = ExitRevisit
 			break
 		}
 		app.ApplyAction(&a)
 	}
-	fmt.Printf("frames: %d\n", frame)
+	res := &Result{Image: app.Image, Frames: frame, Exit: exit}
+	if err := writeResult(outPath, opts.DebugGIF, anim, app.Image); err != nil {
+		return res, err
+	}
+	return res, stratErr
+}
+
+// writeResult writes the debug GIF, if any, and the picture, if outPath is
+// not empty.
+func writeResult(outPath, gifPath string, anim *debugview.GIF, im *image.Paletted) error {
+	if anim != nil {
+		if err := writeDebugGIF(gifPath, anim); err != nil {
+			return err
+		}
+	}
+	if outPath == "" {
+		return nil
+	}
 
 	f, err := os.Create(outPath)
 	if err != nil {
@@ -111,9 +384,8 @@ func Main(inPath, outPath string, seed int64, debug, doTimeLapse bool, maxIter i
 	}
 	defer f.Close()
 	w := bufio.NewWriter(f)
-	if err := png.Encode(w, app.Image); err != nil {
+	if err := png.Encode(w, im); err != nil {
 		return fmt.Errorf("Error encoding %s: %s", outPath, err)
 	}
-	w.Flush()
-	return nil
+	return w.Flush()
 }
@@ -0,0 +1,175 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package critic exhibits artwork from the village to other artists, who
+// review it and build up each other's reputation.
+package critic
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/perception"
+)
+
+// Struct Work is a finished image exhibited by an artist.
+type Work struct {
+	Artist string
+	Image  image.Image
+}
+
+// Struct Critic reviews other artists' work.
+type Critic struct {
+	Name   string
+	Rating perception.Rating
+}
+
+// Struct Review is one critic's score for one artist's work.
+type Review struct {
+	Artist string  `json:"artist"`
+	Critic string  `json:"critic"`
+	Score  float64 `json:"score"`
+}
+
+// Exhibit shows every work to every critic, except for the artist who made it.
+func Exhibit(works []Work, critics []Critic) []Review {
+	var reviews []Review
+	for _, w := range works {
+		for _, c := range critics {
+			if c.Name == w.Artist {
+				continue
+			}
+			reviews = append(reviews, Review{
+				Artist: w.Artist,
+				Critic: c.Name,
+				Score:  c.Rating(w.Image),
+			})
+		}
+	}
+	return reviews
+}
+
+// Scores averages the reviews of each artist.
+func Scores(reviews []Review) map[string]float64 {
+	sums := make(map[string]float64)
+	cnts := make(map[string]int)
+	for _, r := range reviews {
+		sums[r.Artist] += r.Score
+		cnts[r.Artist]++
+	}
+	for a, c := range cnts {
+		sums[a] /= float64(c)
+	}
+	return sums
+}
+
+// Struct Record is what the village remembers about an artist.
+type Record struct {
+	Interests  perception.Interests `json:"interests"`
+	Reputation float64              `json:"reputation"`
+}
+
+// Struct Exhibition is the outcome of one generation.
+type Exhibition struct {
+	Generation int                             `json:"generation"`
+	Reviews    []Review                        `json:"reviews"`
+	Interests  map[string]perception.Interests `json:"interests"`
+}
+
+// Struct Store keeps reputation and interests across runs.
+type Store struct {
+	Generation int                `json:"generation"`
+	Artists    map[string]*Record `json:"artists"`
+	History    []Exhibition       `json:"history"`
+}
+
+// NewStore creates an empty store.
+func NewStore() *Store {
+	return &Store{Artists: make(map[string]*Record)}
+}
+
+// Load reads a store from a JSON file.
+//
+// A missing file is an empty store, so that the first run can start from
+// scratch. Every artist must have one interest per PICO-8 color.
+func Load(path string) (*Store, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return NewStore(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", path, err)
+	}
+	s := NewStore()
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("Error decoding %s: %s", path, err)
+	}
+	for _, n := range s.Names() {
+		r := s.Artists[n]
+		if r == nil {
+			return nil, fmt.Errorf("Error in %s: artist %q has no record", path, n)
+		}
+		if len(r.Interests) != len(palettes.PICO8) {
+			return nil, fmt.Errorf("Error in %s: artist %q has %d interests, expected %d", path, n, len(r.Interests), len(palettes.PICO8))
+		}
+	}
+	return s, nil
+}
+
+// Save writes a store to a JSON file.
+func (s *Store) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding %s: %s", path, err)
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("Error writing %s: %s", path, err)
+	}
+	return nil
+}
+
+// Names returns the names of the artists in the store, sorted.
+func (s *Store) Names() []string {
+	var names []string
+	for n := range s.Artists {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Record remembers an exhibition.
+//
+// Each artist's reputation grows by its average score. Then every artist's
+// interests drift toward the best regarded work by rate.
+func (s *Store) Record(works []Work, reviews []Review, rate float64) {
+	scores := Scores(reviews)
+	var best *Work
+	for i, w := range works {
+		if best == nil || scores[w.Artist] > scores[best.Artist] {
+			best = &works[i]
+		}
+	}
+
+	ex := Exhibition{
+		Generation: s.Generation,
+		Reviews:    reviews,
+		Interests:  make(map[string]perception.Interests),
+	}
+	for _, n := range s.Names() {
+		rec := s.Artists[n]
+		ex.Interests[n] = rec.Interests
+		rec.Reputation += scores[n]
+		if best != nil {
+			rec.Interests = rec.Interests.Drift(best.Image, rate)
+		}
+	}
+	s.History = append(s.History, ex)
+	s.Generation++
+}
@@ -0,0 +1,131 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package critic
+
+import (
+	"image"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/perception"
+)
+
+func newImage(clr int) *image.Paletted {
+	im := image.NewPaletted(image.Rect(0, 0, 4, 4), palettes.PICO8)
+	for i := range im.Pix {
+		im.Pix[i] = uint8(clr)
+	}
+	return im
+}
+
+// likes rates images by how much of a color they have.
+func likes(clr int) perception.Rating {
+	return perception.NewRating(1.0, palettes.PICO8[clr])
+}
+
+func TestExhibitSkipsOwnWork(t *testing.T) {
+	works := []Work{{"a", newImage(8)}, {"b", newImage(11)}}
+	critics := []Critic{{"a", likes(8)}, {"b", likes(8)}, {"c", likes(11)}}
+
+	got := Exhibit(works, critics)
+
+	if len(got) != 4 {
+		t.Fatalf("len(Exhibit(...)) => %d, expected 4", len(got))
+	}
+	for _, r := range got {
+		if r.Artist == r.Critic {
+			t.Errorf("Expected no self reviews, got %#v", r)
+		}
+	}
+	scores := Scores(got)
+	// "a" is reviewed by "b" (1.0) and "c" (0.0).
+	if math.Abs(scores["a"]-0.5) > 0.001 {
+		t.Errorf("Scores(...)[a] => %f, expected 0.5", scores["a"])
+	}
+	// "b" is reviewed by "a" (0.0) and "c" (1.0).
+	if math.Abs(scores["b"]-0.5) > 0.001 {
+		t.Errorf("Scores(...)[b] => %f, expected 0.5", scores["b"])
+	}
+}
+
+func TestRecord(t *testing.T) {
+	s := NewStore()
+	in := make(perception.Interests, len(palettes.PICO8))
+	s.Artists["a"] = &Record{Interests: in}
+	s.Artists["b"] = &Record{Interests: in}
+	works := []Work{{"a", newImage(8)}, {"b", newImage(11)}}
+	reviews := []Review{{"a", "b", 0.25}, {"b", "a", 0.75}}
+
+	s.Record(works, reviews, 0.5)
+
+	if s.Generation != 1 {
+		t.Errorf("s.Generation => %d, expected 1", s.Generation)
+	}
+	if s.Artists["a"].Reputation != 0.25 {
+		t.Errorf("a.Reputation => %f, expected 0.25", s.Artists["a"].Reputation)
+	}
+	// Both drift toward "b", the best regarded work, which is all green.
+	for _, n := range []string{"a", "b"} {
+		got := s.Artists[n].Interests
+		if math.Abs(got[11]-0.5) > 0.001 || got[8] != 0 {
+			t.Errorf("%s.Interests => %v, expected green to drift to 0.5", n, got)
+		}
+	}
+	if len(s.History) != 1 || s.History[0].Interests["a"][11] != 0 {
+		t.Errorf("Expected history to remember interests before the drift, got %#v", s.History)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "critic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "store.json")
+
+	s, err := Load(p)
+	if err != nil {
+		t.Fatalf("Load(missing file) => %s, expected empty store", err)
+	}
+	s.Artists["a"] = &Record{Interests: perception.DefaultInterests, Reputation: 1.5}
+	s.Generation = 3
+	if err := s.Save(p); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Generation != 3 || got.Artists["a"].Reputation != 1.5 {
+		t.Errorf("Load(Save(s)) => %#v, expected %#v", got, s)
+	}
+	if got.Artists["a"].Interests[1] != perception.IdealDarkBlue {
+		t.Errorf("Interests[1] => %f, expected %f", got.Artists["a"].Interests[1], perception.IdealDarkBlue)
+	}
+}
+
+func TestLoadWrongInterests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "critic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "store.json")
+
+	s := NewStore()
+	s.Artists["a"] = &Record{Interests: perception.Interests{0.5, 0.5}}
+	if err := s.Save(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(p); err == nil {
+		t.Error("Load(store with 2 interests) => nil error, expected error")
+	}
+}
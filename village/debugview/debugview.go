@@ -0,0 +1,222 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package debugview draws why a strategy chose its action on top of the
+// screen.
+//
+// The screen is upscaled, then:
+//   - each candidate action is drawn as a heatmap cell where the cursor would
+//     move, with the left half for releasing and the right half for pressing,
+//   - the chosen action's cell is outlined in white,
+//   - the pixel the strategy expects to paint is outlined in yellow,
+//   - the path to the palette button (if choosing a color) and then to the
+//     pixel is drawn as a line.
+package debugview
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/gui"
+	"github.com/tswast/pixelsketches/village/strategy"
+)
+
+// heatSteps is the number of colors in the heatmap ramp.
+const heatSteps = 32
+
+var (
+	white  = color.RGBA{255, 255, 255, 255}
+	yellow = color.RGBA{255, 255, 0, 255}
+	gray   = color.RGBA{64, 64, 64, 255}
+)
+
+// Palette contains every color used by Render, so that frames can be
+// encoded as a GIF without dithering.
+var Palette = func() color.Palette {
+	var p color.Palette
+	p = append(p, palettes.PICO8...)
+	for i := 0; i < heatSteps; i++ {
+		p = append(p, heat(float64(i)/float64(heatSteps-1)))
+	}
+	p = append(p, white, yellow, gray)
+	return p
+}()
+
+// heat returns the ramp color for a value in [0, 1], from blue to red.
+func heat(v float64) color.RGBA {
+	i := uint8(v * 255)
+	return color.RGBA{i, 0, 255 - i, 255}
+}
+
+func fill(im draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(im, r, &image.Uniform{c}, image.ZP, draw.Src)
+}
+
+func outline(im draw.Image, r image.Rectangle, c color.Color) {
+	fill(im, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1), c)
+	fill(im, image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y), c)
+	fill(im, image.Rect(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y), c)
+	fill(im, image.Rect(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y), c)
+}
+
+// line draws a line between two points with Bresenham's algorithm.
+func line(im draw.Image, a, b image.Point, c color.Color) {
+	dx := b.X - a.X
+	if dx < 0 {
+		dx = -dx
+	}
+	dy := -(b.Y - a.Y)
+	if dy > 0 {
+		dy = -dy
+	}
+	sx, sy := 1, 1
+	if a.X > b.X {
+		sx = -1
+	}
+	if a.Y > b.Y {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		im.Set(a.X, a.Y, c)
+		if a == b {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			a.X += sx
+		}
+		if e2 <= dx {
+			e += dx
+			a.Y += sy
+		}
+	}
+}
+
+// cell returns the upscaled rectangle of a screen pixel.
+func cell(pt image.Point, scale int) image.Rectangle {
+	return image.Rect(pt.X*scale, pt.Y*scale, (pt.X+1)*scale, (pt.Y+1)*scale)
+}
+
+func center(pt image.Point, scale int) image.Point {
+	return image.Point{X: pt.X*scale + scale/2, Y: pt.Y*scale + scale/2}
+}
+
+// target returns the cursor position after an action, clamped to the screen
+// like gui.ApplyAction.
+func target(pos image.Point, act gui.Action) image.Point {
+	app := gui.AppState{Cursor: gui.Cursor{Pos: pos}}
+	app.ApplyAction(&gui.Action{Horizontal: act.Horizontal, Vertical: act.Vertical})
+	return app.Cursor.Pos
+}
+
+// paintReason finds the pixel a Reason expects to paint, and whether it
+// chooses a new color first.
+func paintReason(r strategy.Reason) (*strategy.PaintReason, bool) {
+	switch r := r.(type) {
+	case *strategy.PaintReason:
+		return r, false
+	case *strategy.ChooseColorReason:
+		p, _ := paintReason(r.Reason)
+		return p, true
+	}
+	return nil, false
+}
+
+// Render draws the screen of app, upscaled by scale, annotated with a trace.
+func Render(app *gui.AppState, t strategy.Trace, scale int) *image.Paletted {
+	scr := app.DrawScreen()
+	r := image.Rect(0, 0, gui.ScreenWidth*scale, gui.ScreenHeight*scale)
+	out := image.NewPaletted(r, Palette)
+	for x := 0; x < gui.ScreenWidth; x++ {
+		for y := 0; y < gui.ScreenHeight; y++ {
+			fill(out, cell(image.Point{x, y}, scale), scr.At(x, y))
+		}
+	}
+
+	// Normalize candidate ratings, ignoring impossible actions.
+	lo, hi := 0.0, 0.0
+	first := true
+	for _, c := range t.Candidates {
+		if c.Rating.Rate < 0 {
+			continue
+		}
+		if first || c.Rating.Rate < lo {
+			lo = c.Rating.Rate
+		}
+		if first || c.Rating.Rate > hi {
+			hi = c.Rating.Rate
+		}
+		first = false
+	}
+	pos := app.Cursor.Pos
+	for _, c := range t.Candidates {
+		cr := cell(target(pos, c.Action), scale).Inset(1)
+		half := cr
+		if c.Action.Pressed {
+			half.Min.X = cr.Min.X + cr.Dx()/2
+		} else {
+			half.Max.X = cr.Min.X + cr.Dx()/2
+		}
+		clr := color.Color(gray)
+		if c.Rating.Rate >= 0 {
+			v := 1.0
+			if hi > lo {
+				v = (c.Rating.Rate - lo) / (hi - lo)
+			}
+			clr = heat(v)
+		}
+		fill(out, half, clr)
+	}
+	if len(t.Candidates) > 0 {
+		outline(out, cell(target(pos, t.Chosen), scale), white)
+	}
+
+	p, chooses := paintReason(t.Rating.Reason)
+	if p == nil {
+		return out
+	}
+	tgt := image.Point{X: p.Pos.X + gui.ImageX, Y: p.Pos.Y}
+	from := center(pos, scale)
+	if chooses {
+		for i, c := range app.Image.Palette {
+			if c != p.NewColor {
+				continue
+			}
+			btn := image.Point{
+				X: gui.ImageX - gui.ButtonBuffer - 2,
+				Y: i*gui.ButtonHeight + gui.ButtonHeight/2,
+			}
+			line(out, from, center(btn, scale), white)
+			from = center(btn, scale)
+			break
+		}
+	}
+	line(out, from, center(tgt, scale), yellow)
+	outline(out, cell(tgt, scale), yellow)
+	return out
+}
+
+// Struct GIF collects rendered frames into an animation.
+type GIF struct {
+	// Delay is the time between frames, in 100ths of a second.
+	Delay int
+	anim  gif.GIF
+}
+
+// Add appends a frame.
+func (g *GIF) Add(im *image.Paletted) {
+	g.anim.Image = append(g.anim.Image, im)
+	g.anim.Delay = append(g.anim.Delay, g.Delay)
+}
+
+// Encode writes the animation.
+func (g *GIF) Encode(w io.Writer) error {
+	return gif.EncodeAll(w, &g.anim)
+}
@@ -0,0 +1,90 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package debugview
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/gif"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/gui"
+	"github.com/tswast/pixelsketches/village/perception"
+	"github.com/tswast/pixelsketches/village/strategy"
+)
+
+func TestTargetClamps(t *testing.T) {
+	got := target(image.Point{0, 0}, gui.Action{Horizontal: -1, Vertical: -1})
+	if got != (image.Point{0, 0}) {
+		t.Errorf("target({0, 0}, up-left) => %v, expected {0, 0}", got)
+	}
+	got = target(image.Point{5, 5}, gui.Action{Horizontal: 1, Vertical: -1})
+	if got != (image.Point{6, 4}) {
+		t.Errorf("target({5, 5}, up-right) => %v, expected {6, 4}", got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	app := gui.NewAppState()
+	app.Cursor.Pos = image.Point{X: gui.ImageX + 10, Y: 10}
+	s := &strategy.Ideal{Rating: perception.NewRating(1.0, palettes.PICO8_PINK)}
+	tr := strategy.StrategizeTrace(context.Background(), s, app)
+	const scale = 4
+
+	im := Render(app, tr, scale)
+
+	if w, h := im.Bounds().Dx(), im.Bounds().Dy(); w != gui.ScreenWidth*scale || h != gui.ScreenHeight*scale {
+		t.Fatalf("Render(...) size => %dx%d, expected %dx%d", w, h, gui.ScreenWidth*scale, gui.ScreenHeight*scale)
+	}
+	// The chosen cell is outlined.
+	c := cell(target(app.Cursor.Pos, tr.Chosen), scale)
+	if got := im.At(c.Min.X, c.Min.Y); got != white {
+		t.Errorf("chosen cell corner => %v, expected %v", got, white)
+	}
+	// Pink isn't selected, so the strategy must choose it, then paint.
+	p, chooses := paintReason(tr.Rating.Reason)
+	if p == nil || !chooses {
+		t.Fatalf("paintReason(%s) => %v, %v, expected to choose a color", tr.Rating.Reason.Explain(), p, chooses)
+	}
+	tc := cell(image.Point{X: p.Pos.X + gui.ImageX, Y: p.Pos.Y}, scale)
+	if got := im.At(tc.Max.X-1, tc.Max.Y-1); got != yellow {
+		t.Errorf("target cell corner => %v, expected %v", got, yellow)
+	}
+}
+
+func TestRenderEmptyTrace(t *testing.T) {
+	app := gui.NewAppState()
+	im := Render(app, strategy.Trace{}, 1)
+	scr := app.DrawScreen()
+	for x := 0; x < gui.ScreenWidth; x++ {
+		for y := 0; y < gui.ScreenHeight; y++ {
+			r1, g1, b1, _ := im.At(x, y).RGBA()
+			r2, g2, b2, _ := scr.At(x, y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 {
+				t.Fatalf("Render(app, Trace{}, 1).At(%d, %d) differs from DrawScreen", x, y)
+			}
+		}
+	}
+}
+
+func TestGIF(t *testing.T) {
+	app := gui.NewAppState()
+	g := &GIF{Delay: 5}
+	g.Add(Render(app, strategy.Trace{}, 2))
+	g.Add(Render(app, strategy.Trace{}, 2))
+	var b bytes.Buffer
+	if err := g.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := gif.DecodeAll(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Image) != 2 || got.Delay[1] != 5 {
+		t.Errorf("DecodeAll(Encode(g)) => %d frames, delay %v, expected 2 frames, delay 5", len(got.Image), got.Delay)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package evolve searches for images with a genetic algorithm.
+//
+// Each individual is an image, and its fitness is a perception.Rating.
+package evolve
+
+import (
+	"image"
+	"image/draw"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/tswast/pixelsketches/village/perception"
+)
+
+// Struct Individual is one image in the population.
+type Individual struct {
+	Image   *image.Paletted
+	Fitness float64
+}
+
+// Struct Config controls how the population evolves.
+type Config struct {
+	// Size is the number of individuals in each generation.
+	Size int
+	// Elites is the number of best individuals copied unchanged to the next
+	// generation.
+	Elites int
+	// Tournament is the number of individuals competing to be a parent.
+	Tournament int
+	// Crossover is the probability a child combines two parents.
+	Crossover float64
+	// Pixels is the number of random pixels mutated in each child.
+	Pixels int
+	// Rect is the probability a child has a random rectangle painted.
+	Rect float64
+}
+
+// DefaultConfig is a reasonable configuration for 64x64 images.
+var DefaultConfig = Config{
+	Size:       50,
+	Elites:     2,
+	Tournament: 3,
+	Crossover:  0.7,
+	Pixels:     4,
+	Rect:       0.3,
+}
+
+// Struct Population is a generation of individuals.
+type Population struct {
+	Individuals []*Individual
+	Generation  int
+	Config      Config
+	Rating      perception.Rating
+
+	rng *rand.Rand
+}
+
+func copyImage(im *image.Paletted) *image.Paletted {
+	out := image.NewPaletted(im.Bounds(), im.Palette)
+	copy(out.Pix, im.Pix)
+	return out
+}
+
+// valid returns cfg with a Size of at least 1 and between 0 and Size elites.
+//
+// A Size less than 1 falls back to DefaultConfig.Size.
+func (cfg Config) valid() Config {
+	if cfg.Size < 1 {
+		cfg.Size = DefaultConfig.Size
+	}
+	if cfg.Elites < 0 {
+		cfg.Elites = 0
+	}
+	if cfg.Elites > cfg.Size {
+		cfg.Elites = cfg.Size
+	}
+	return cfg
+}
+
+// New creates the first generation of a population.
+//
+// Every individual starts as a copy of seed with a random rectangle painted
+// on it. A cfg with a Size less than 1 uses DefaultConfig.Size, and Elites is
+// clamped between 0 and Size.
+func New(seed *image.Paletted, rating perception.Rating, cfg Config, rng *rand.Rand) *Population {
+	cfg = cfg.valid()
+	p := &Population{Config: cfg, Rating: rating, rng: rng}
+	for i := 0; i < cfg.Size; i++ {
+		im := copyImage(seed)
+		MutateRect(im, rng)
+		p.Individuals = append(p.Individuals, &Individual{Image: im})
+	}
+	p.evaluate(p.Individuals)
+	p.sort()
+	return p
+}
+
+// evaluate rates individuals in parallel.
+func (p *Population) evaluate(inds []*Individual) {
+	var wg sync.WaitGroup
+	for _, ind := range inds {
+		wg.Add(1)
+		go func(ind *Individual) {
+			defer wg.Done()
+			ind.Fitness = p.Rating(ind.Image)
+		}(ind)
+	}
+	wg.Wait()
+}
+
+// sort orders individuals from most fit to least fit.
+func (p *Population) sort() {
+	sort.SliceStable(p.Individuals, func(i, j int) bool {
+		return p.Individuals[i].Fitness > p.Individuals[j].Fitness
+	})
+}
+
+// Best returns the most fit individual.
+func (p *Population) Best() *Individual {
+	return p.Individuals[0]
+}
+
+// Mean returns the average fitness.
+func (p *Population) Mean() float64 {
+	t := 0.0
+	for _, ind := range p.Individuals {
+		t += ind.Fitness
+	}
+	return t / float64(len(p.Individuals))
+}
+
+// Select chooses a parent by tournament selection.
+func (p *Population) Select() *Individual {
+	var best *Individual
+	for i := 0; i < p.Config.Tournament || best == nil; i++ {
+		ind := p.Individuals[p.rng.Intn(len(p.Individuals))]
+		if best == nil || ind.Fitness > best.Fitness {
+			best = ind
+		}
+	}
+	return best
+}
+
+// Evolve replaces the population with the next generation.
+func (p *Population) Evolve() {
+	next := make([]*Individual, 0, len(p.Individuals))
+	for i := 0; i < p.Config.Elites && i < len(p.Individuals); i++ {
+		next = append(next, p.Individuals[i])
+	}
+	var children []*Individual
+	for len(next)+len(children) < len(p.Individuals) {
+		im := copyImage(p.Select().Image)
+		if p.rng.Float64() < p.Config.Crossover {
+			Crossover(im, p.Select().Image, p.rng)
+		}
+		for i := 0; i < p.Config.Pixels; i++ {
+			MutatePixel(im, p.rng)
+		}
+		if p.rng.Float64() < p.Config.Rect {
+			MutateRect(im, p.rng)
+		}
+		children = append(children, &Individual{Image: im})
+	}
+	p.evaluate(children)
+	p.Individuals = append(next, children...)
+	p.sort()
+	p.Generation++
+}
+
+// randomRect chooses a random rectangle inside of b.
+func randomRect(b image.Rectangle, rng *rand.Rand) image.Rectangle {
+	x0 := b.Min.X + rng.Intn(b.Dx())
+	x1 := b.Min.X + rng.Intn(b.Dx())
+	y0 := b.Min.Y + rng.Intn(b.Dy())
+	y1 := b.Min.Y + rng.Intn(b.Dy())
+	return image.Rect(x0, y0, x1+1, y1+1).Canon()
+}
+
+// MutatePixel sets a random pixel to a random color.
+func MutatePixel(im *image.Paletted, rng *rand.Rand) {
+	b := im.Bounds()
+	x := b.Min.X + rng.Intn(b.Dx())
+	y := b.Min.Y + rng.Intn(b.Dy())
+	im.SetColorIndex(x, y, uint8(rng.Intn(len(im.Palette))))
+}
+
+// MutateRect fills a random rectangle with a random color.
+func MutateRect(im *image.Paletted, rng *rand.Rand) {
+	r := randomRect(im.Bounds(), rng)
+	clr := im.Palette[rng.Intn(len(im.Palette))]
+	draw.Draw(im, r, &image.Uniform{clr}, image.ZP, draw.Src)
+}
+
+// Crossover copies a random region of the other parent into im.
+func Crossover(im, other *image.Paletted, rng *rand.Rand) {
+	r := randomRect(im.Bounds(), rng)
+	draw.Draw(im, r, other, r.Min, draw.Src)
+}
@@ -0,0 +1,102 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package evolve
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/perception"
+)
+
+func newImage() *image.Paletted {
+	return image.NewPaletted(image.Rect(0, 0, 16, 16), palettes.PICO8)
+}
+
+func TestMutateRect(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		im := newImage()
+		MutateRect(im, rng)
+		for _, c := range im.Pix {
+			if int(c) >= len(palettes.PICO8) {
+				t.Fatalf("Expected palette index, got %d", c)
+			}
+		}
+	}
+}
+
+func TestCrossover(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	im := newImage()
+	other := newImage()
+	for i := range other.Pix {
+		other.Pix[i] = 14
+	}
+	Crossover(im, other, rng)
+	// The region is a rectangle, so every pixel is either from im or other.
+	pink := 0
+	for _, c := range im.Pix {
+		if c == 14 {
+			pink++
+		} else if c != 0 {
+			t.Fatalf("Expected pixel from a parent, got %d", c)
+		}
+	}
+	if pink == 0 {
+		t.Error("Expected at least one pixel from the other parent.")
+	}
+}
+
+func TestSelectWholePopulation(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cfg := DefaultConfig
+	cfg.Size = 5
+	// With a huge tournament, the best individual always wins.
+	cfg.Tournament = 1000
+	p := New(newImage(), perception.NewRating(1.0, palettes.PICO8_PINK), cfg, rng)
+	for i := 0; i < 10; i++ {
+		if got := p.Select(); got.Fitness != p.Best().Fitness {
+			t.Errorf("Select() => fitness %f, expected best %f", got.Fitness, p.Best().Fitness)
+		}
+	}
+}
+
+func TestEvolveKeepsElites(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cfg := DefaultConfig
+	cfg.Size = 20
+	p := New(newImage(), perception.NewRating(1.0, palettes.PICO8_PINK), cfg, rng)
+	prev := p.Best().Fitness
+	for i := 0; i < 30; i++ {
+		p.Evolve()
+		if p.Best().Fitness < prev {
+			t.Fatalf("generation %d: best fitness dropped from %f to %f", p.Generation, prev, p.Best().Fitness)
+		}
+		prev = p.Best().Fitness
+		if len(p.Individuals) != cfg.Size {
+			t.Fatalf("len(p.Individuals) => %d, expected %d", len(p.Individuals), cfg.Size)
+		}
+	}
+	if prev <= 0 {
+		t.Errorf("Expected some pink after evolving, got fitness %f", prev)
+	}
+}
+
+func TestNewZeroConfig(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	p := New(newImage(), perception.NewRating(1.0, palettes.PICO8_PINK), Config{Elites: 100}, rng)
+	if len(p.Individuals) != DefaultConfig.Size {
+		t.Fatalf("len(p.Individuals) => %d, expected %d", len(p.Individuals), DefaultConfig.Size)
+	}
+	if p.Config.Elites != DefaultConfig.Size {
+		t.Errorf("p.Config.Elites => %d, expected %d", p.Config.Elites, DefaultConfig.Size)
+	}
+	p.Best()
+	p.Mean()
+	p.Evolve()
+}
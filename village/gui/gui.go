@@ -30,9 +30,9 @@ const (
 // It remembers where a button press first started, since that information is
 // used to determine if button actions will happen or not.
 type Cursor struct {
-	Pos      image.Point
-	Pressed  bool
-	PressPos image.Point
+	Pos      image.Point `json:"pos"`
+	Pressed  bool        `json:"pressed"`
+	PressPos image.Point `json:"press_pos"`
 }
 
 const (
@@ -50,9 +50,9 @@ type AppState struct {
 
 // Stuct Action specifies how the app state should change.
 type Action struct {
-	Pressed    bool
-	Horizontal int
-	Vertical   int
+	Pressed    bool `json:"pressed"`
+	Horizontal int  `json:"horizontal"`
+	Vertical   int  `json:"vertical"`
 }
 
 func newImage() *image.Paletted {
@@ -359,6 +359,6 @@ func TestApplyActionExitsDrawingMode(t *testing.T) {
 	if app.Mode != MODE_DONE {
 		t.Errorf(
 			"(&AppState{Cursor: %v}).ApplyAction(&Action{}) =>\n\tAppState{Mode: %v},\twant AppState{Mode: MODE_DONE}",
-			app.Cursor)
+			app.Cursor, app.Mode)
 	}
 }
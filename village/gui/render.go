@@ -120,10 +120,16 @@ func (app *AppState) DrawScreen() *image.NRGBA {
 		im,
 		image.ZP,
 		draw.Src)
-	// Draw cursor
+	DrawCursor(scr, app.Cursor)
+	return scr
+}
+
+// DrawCursor draws a cursor onto a screen drawn by DrawScreen.
+//
+// This is useful to show more than one cursor on a shared screen.
+func DrawCursor(scr draw.Image, c Cursor) {
 	// Choose a different color every time, so it is easier to track where the
 	// cursor is.
-	csr := (app.Cursor.Pos.X + app.Cursor.Pos.Y) % len(palettes.PICO8)
-	scr.Set(app.Cursor.Pos.X, app.Cursor.Pos.Y, palettes.PICO8[csr])
-	return scr
+	csr := (c.Pos.X + c.Pos.Y) % len(palettes.PICO8)
+	scr.Set(c.Pos.X, c.Pos.Y, palettes.PICO8[csr])
 }
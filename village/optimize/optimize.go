@@ -0,0 +1,155 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package optimize maximizes a perception.Rating by changing pixels directly.
+//
+// Unlike the strategies, the optimizers don't use the gui, so they are useful
+// to estimate the best rating an image could get.
+package optimize
+
+import (
+	"image"
+	"math"
+	"math/rand"
+
+	"github.com/tswast/pixelsketches/village/perception"
+)
+
+// Cooling gives the temperature for simulated annealing at a step.
+type Cooling func(step, steps int) float64
+
+// Linear cools from t0 to 0 at a constant rate.
+func Linear(t0 float64) Cooling {
+	return func(step, steps int) float64 {
+		return t0 * (1.0 - float64(step)/float64(steps))
+	}
+}
+
+// Exponential multiplies the temperature by alpha each step.
+func Exponential(t0, alpha float64) Cooling {
+	return func(step, _ int) float64 {
+		return t0 * math.Pow(alpha, float64(step))
+	}
+}
+
+// Logarithmic cools slowly, in proportion to 1 / log(step).
+func Logarithmic(t0 float64) Cooling {
+	return func(step, _ int) float64 {
+		return t0 / math.Log(float64(step)+math.E)
+	}
+}
+
+// Struct Result is the best image found by an optimizer.
+type Result struct {
+	Image  *image.Paletted
+	Rating float64
+	// Steps is the number of ratings calculated.
+	Steps int
+}
+
+// copyImage copies an image, which may be a sub-image, into one whose Pix is
+// just its pixels.
+func copyImage(im *image.Paletted) *image.Paletted {
+	b := im.Bounds()
+	out := image.NewPaletted(b, im.Palette)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := im.PixOffset(b.Min.X, y)
+		copy(out.Pix[out.PixOffset(b.Min.X, y):], im.Pix[i:i+b.Dx()])
+	}
+	return out
+}
+
+// Randomize sets every pixel to a random color.
+func Randomize(im *image.Paletted, rng *rand.Rand) {
+	b := im.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := im.PixOffset(b.Min.X, y)
+		for x := range im.Pix[i : i+b.Dx()] {
+			im.Pix[i+x] = uint8(rng.Intn(len(im.Palette)))
+		}
+	}
+}
+
+// mutate sets a random pixel to a different random color. A palette of one
+// color has no other color, so then nothing changes.
+//
+// The image must be a copy from copyImage, so that every byte of Pix is a
+// pixel. It returns the offset of the pixel and the old color index, so that
+// the change can be undone.
+func mutate(im *image.Paletted, rng *rand.Rand) (int, uint8) {
+	i := rng.Intn(len(im.Pix))
+	old := im.Pix[i]
+	if len(im.Palette) < 2 {
+		return i, old
+	}
+	c := uint8(rng.Intn(len(im.Palette) - 1))
+	if c >= old {
+		c++
+	}
+	im.Pix[i] = c
+	return i, old
+}
+
+// Anneal maximizes a rating with simulated annealing.
+//
+// A worse image is accepted with probability exp(delta / temperature), so it
+// can escape local maxima while the temperature is high.
+func Anneal(start *image.Paletted, rating perception.Rating, steps int, cool Cooling, rng *rand.Rand) Result {
+	im := copyImage(start)
+	cur := rating(im)
+	best := Result{Image: copyImage(im), Rating: cur, Steps: 1}
+	for step := 0; step < steps; step++ {
+		i, old := mutate(im, rng)
+		r := rating(im)
+		best.Steps++
+		delta := r - cur
+		temp := cool(step, steps)
+		if delta >= 0 || (temp > 0 && rng.Float64() < math.Exp(delta/temp)) {
+			cur = r
+			if cur > best.Rating {
+				best.Rating = cur
+				copy(best.Image.Pix, im.Pix)
+			}
+		} else {
+			im.Pix[i] = old
+		}
+	}
+	return best
+}
+
+// climb accepts changes which don't lower the rating.
+func climb(im *image.Paletted, rating perception.Rating, steps int, rng *rand.Rand) float64 {
+	cur := rating(im)
+	for step := 0; step < steps; step++ {
+		i, old := mutate(im, rng)
+		r := rating(im)
+		if r >= cur {
+			cur = r
+		} else {
+			im.Pix[i] = old
+		}
+	}
+	return cur
+}
+
+// HillClimb maximizes a rating with random-restart hill climbing.
+//
+// The first climb begins at start, and each restart begins at random noise.
+// Every climb takes the given number of steps.
+func HillClimb(start *image.Paletted, rating perception.Rating, steps, restarts int, rng *rand.Rand) Result {
+	var best Result
+	im := copyImage(start)
+	for i := 0; i <= restarts; i++ {
+		if i > 0 {
+			Randomize(im, rng)
+		}
+		r := climb(im, rating, steps, rng)
+		best.Steps += steps + 1
+		if best.Image == nil || r > best.Rating {
+			best.Rating = r
+			best.Image = copyImage(im)
+		}
+	}
+	return best
+}
@@ -0,0 +1,128 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package optimize
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/perception"
+)
+
+func newImage() *image.Paletted {
+	return image.NewPaletted(image.Rect(0, 0, 4, 4), palettes.PICO8)
+}
+
+var coolingtests = []struct {
+	name     string
+	cool     Cooling
+	step     int
+	expected float64
+}{
+	{"linear start", Linear(2), 0, 2},
+	{"linear middle", Linear(2), 50, 1},
+	{"linear end", Linear(2), 100, 0},
+	{"exponential start", Exponential(2, 0.5), 0, 2},
+	{"exponential", Exponential(2, 0.5), 2, 0.5},
+	{"logarithmic start", Logarithmic(2), 0, 2},
+}
+
+func TestCooling(t *testing.T) {
+	for _, tt := range coolingtests {
+		got := tt.cool(tt.step, 100)
+		if math.Abs(got-tt.expected) > 0.001 {
+			t.Errorf("%s: cool(%d, 100) => %f, expected %f", tt.name, tt.step, got, tt.expected)
+		}
+	}
+}
+
+func TestMutateChangesColor(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	im := newImage()
+	for i := 0; i < 100; i++ {
+		j, old := mutate(im, rng)
+		if im.Pix[j] == old {
+			t.Fatalf("mutate(im) left pixel %d as %d", j, old)
+		}
+		if int(im.Pix[j]) >= len(palettes.PICO8) {
+			t.Fatalf("mutate(im) set pixel %d to %d, outside palette", j, im.Pix[j])
+		}
+	}
+}
+
+func TestAnneal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	start := newImage()
+	rating := perception.NewRating(0.5, palettes.PICO8_PINK)
+	got := Anneal(start, rating, 2000, Exponential(0.1, 0.995), rng)
+	if got.Rating < 0.99 {
+		t.Errorf("Anneal(...).Rating => %f, expected about 1.0", got.Rating)
+	}
+	if r := rating(got.Image); r != got.Rating {
+		t.Errorf("rating(Anneal(...).Image) => %f, but Rating is %f", r, got.Rating)
+	}
+	if start.Pix[0] != 0 {
+		t.Error("Anneal modified the start image.")
+	}
+}
+
+func TestHillClimb(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	rating := perception.NewRating(0.5, palettes.PICO8_PINK)
+	got := HillClimb(newImage(), rating, 500, 3, rng)
+	if got.Rating < 0.99 {
+		t.Errorf("HillClimb(...).Rating => %f, expected about 1.0", got.Rating)
+	}
+	if got.Steps != 4*501 {
+		t.Errorf("HillClimb(...).Steps => %d, expected %d", got.Steps, 4*501)
+	}
+}
+
+func TestSubImage(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	full := image.NewPaletted(image.Rect(0, 0, 8, 8), palettes.PICO8)
+	r := image.Rect(2, 2, 6, 6)
+	sub := full.SubImage(r).(*image.Paletted)
+	sub.Set(2, 2, palettes.PICO8_PINK)
+
+	cp := copyImage(sub)
+	if cp.Bounds() != r || cp.At(2, 2) != palettes.PICO8_PINK || cp.At(3, 2) != palettes.PICO8_BLACK {
+		t.Errorf("copyImage(sub) => %v with %v at (2, 2), expected %v with only pink there", cp.Bounds(), cp.At(2, 2), r)
+	}
+
+	// Only the pixels of the sub-image change.
+	Randomize(sub, rng)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if !(image.Point{x, y}).In(r) && full.ColorIndexAt(x, y) != 0 {
+				t.Fatalf("Randomize(sub) changed (%d, %d), outside %v", x, y, r)
+			}
+		}
+	}
+
+	rating := perception.NewRating(0.5, palettes.PICO8_PINK)
+	got := Anneal(sub, rating, 200, Exponential(0.1, 0.995), rng)
+	if got.Image.Bounds() != r {
+		t.Errorf("Anneal(sub).Image.Bounds() => %v, expected %v", got.Image.Bounds(), r)
+	}
+	if r := rating(got.Image); r != got.Rating {
+		t.Errorf("rating(Anneal(sub).Image) => %f, but Rating is %f", r, got.Rating)
+	}
+}
+
+func TestOneColor(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	im := image.NewPaletted(image.Rect(0, 0, 4, 4), palettes.PICO8[:1])
+	rating := perception.NewRating(0.5, palettes.PICO8_BLACK)
+	if got := Anneal(im, rating, 10, Linear(1), rng); got.Rating != rating(im) {
+		t.Errorf("Anneal(one color).Rating => %f, expected %f", got.Rating, rating(im))
+	}
+	if got := HillClimb(im, rating, 10, 1, rng); got.Rating != rating(im) {
+		t.Errorf("HillClimb(one color).Rating => %f, expected %f", got.Rating, rating(im))
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"bytes"
+	"compress/flate"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+// IdealComplexity is the flate size at which RateGoldilocks peaks: well
+// above a flat canvas, well below noise.
+const IdealComplexity = 0.15
+
+// paletteIndexes returns the PICO-8 palette index of each pixel, row by row.
+func paletteIndexes(im image.Image) []byte {
+	b := im.Bounds()
+	idx := make([]byte, 0, b.Dx()*b.Dy())
+	p, isPaletted := paletted(im)
+	// Other palettes may have indexes that do not fit in 4 bits.
+	isPaletted = isPaletted && len(p.Palette) <= len(palettes.PICO8)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if isPaletted {
+				idx = append(idx, p.ColorIndexAt(x, y))
+			} else {
+				idx = append(idx, byte(color.Palette(palettes.PICO8).Index(im.At(x, y))))
+			}
+		}
+	}
+	return idx
+}
+
+// entropy is the Shannon entropy, in bits, of the counts.
+func entropy(cnts map[int]int, total int) float64 {
+	// Sum in order of the keys, so the same image always rates the same.
+	keys := make([]int, 0, len(cnts))
+	for k := range cnts {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	h := 0.0
+	for _, k := range keys {
+		p := float64(cnts[k]) / float64(total)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// RateEntropy rates an image by the Shannon entropy of its colors, from 0
+// for one color to 1 for all colors equally often.
+func RateEntropy(im image.Image) float64 {
+	idx := paletteIndexes(im)
+	if len(idx) == 0 {
+		return 0.0
+	}
+	cnts := make(map[int]int)
+	for _, i := range idx {
+		cnts[int(i)]++
+	}
+	return entropy(cnts, len(idx)) / math.Log2(float64(len(palettes.PICO8)))
+}
+
+// RateBlockEntropy rates an image by the Shannon entropy of its 2x2 blocks
+// of colors, which unlike RateEntropy notices how the colors are arranged.
+//
+// It is normalized by the most entropy possible for the number of blocks.
+func RateBlockEntropy(im image.Image) float64 {
+	b := im.Bounds()
+	w, h := b.Dx(), b.Dy()
+	blocks := (w - 1) * (h - 1)
+	if w < 2 || h < 2 || blocks < 2 {
+		return 0.0
+	}
+	idx := paletteIndexes(im)
+	cnts := make(map[int]int)
+	for y := 0; y < h-1; y++ {
+		for x := 0; x < w-1; x++ {
+			k := int(idx[y*w+x])
+			k = k<<4 | int(idx[y*w+x+1])
+			k = k<<4 | int(idx[(y+1)*w+x])
+			k = k<<4 | int(idx[(y+1)*w+x+1])
+			cnts[k]++
+		}
+	}
+	maxBlocks := math.Pow(float64(len(palettes.PICO8)), 4)
+	return entropy(cnts, blocks) / math.Log2(math.Min(float64(blocks), maxBlocks))
+}
+
+// Struct countWriter counts the bytes written to it.
+type countWriter struct {
+	n int
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += len(p)
+	return len(p), nil
+}
+
+// flateWriters are reused, since each allocates about a megabyte and
+// strategies rate every candidate action.
+var flateWriters = sync.Pool{
+	New: func() interface{} {
+		fw, err := flate.NewWriter(nil, flate.BestCompression)
+		if err != nil {
+			// Only an invalid level is an error.
+			panic(err)
+		}
+		return fw
+	},
+}
+
+// flateSize is the length of data compressed with compress/flate.
+func flateSize(data []byte) int {
+	fw := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(fw)
+	var cw countWriter
+	fw.Reset(&cw)
+	fw.Write(data)
+	fw.Close()
+	return cw.n
+}
+
+// flatSizes remembers the flateSize of n zero bytes, by n, since it only
+// depends on the length.
+var flatSizes = struct {
+	sync.Mutex
+	m map[int]int
+}{m: make(map[int]int)}
+
+// flatSize is the flateSize of n zero bytes.
+func flatSize(n int) int {
+	flatSizes.Lock()
+	defer flatSizes.Unlock()
+	s, ok := flatSizes.m[n]
+	if !ok {
+		s = flateSize(make([]byte, n))
+		flatSizes.m[n] = s
+	}
+	return s
+}
+
+// RateFlateSize rates an image by how poorly its palette indexes compress,
+// from 0 for a flat image to about 1 for noise.
+//
+// The indexes are packed two to a byte, so noise is incompressible, and the
+// size is measured above that of a flat image of the same size.
+func RateFlateSize(im image.Image) float64 {
+	idx := paletteIndexes(im)
+	if len(idx) == 0 {
+		return 0.0
+	}
+	packed := make([]byte, (len(idx)+1)/2)
+	for i, c := range idx {
+		packed[i/2] |= c << uint(4*(i%2))
+	}
+	flat := flatSize(len(packed))
+	return clamp(float64(flateSize(packed)-flat) / float64(len(packed)))
+}
+
+// pngSize is the length of an image encoded as a PNG.
+func pngSize(im image.Image) int {
+	// Encode the paletted image itself, which png encodes with its palette.
+	if p, ok := paletted(im); ok {
+		im = p
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, im); err != nil {
+		// Encoding to memory only fails for invalid images.
+		panic(err)
+	}
+	return buf.Len()
+}
+
+// RatePNGSize rates an image by the size of its PNG, from 0 for a flat image
+// to about 1 for noise.
+//
+// Unlike RateFlateSize, PNG filters predict each row from the one above, so
+// repeated rows cost little.
+func RatePNGSize(im image.Image) float64 {
+	b := im.Bounds()
+	if b.Empty() {
+		return 0.0
+	}
+	pim, _ := ToPalette(im, palettes.PICO8)
+	flat := pngSize(image.NewPaletted(pim.Bounds(), palettes.PICO8))
+	// Noise takes 4 bits per pixel, plus a filter byte per row.
+	noise := (b.Dx()+1)/2*b.Dy() + b.Dy()
+	return clamp(float64(pngSize(pim)-flat) / float64(noise))
+}
+
+// clamp limits x to [0, 1].
+func clamp(x float64) float64 {
+	return math.Max(0.0, math.Min(x, 1.0))
+}
+
+// NewComplexityRating creates a rating function which desires an ideal
+// complexity, measured by RateFlateSize.
+//
+// Like RateImage, the value is 0 at the endpoints and 1 at the ideal value.
+func NewComplexityRating(ideal float64) Rating {
+	return func(im image.Image) float64 {
+		return tent(RateFlateSize(im), ideal)
+	}
+}
+
+// RateGoldilocks rates an image by how close it is to IdealComplexity, so
+// neither a flat canvas nor noise rates well.
+func RateGoldilocks(im image.Image) float64 {
+	return NewComplexityRating(IdealComplexity)(im)
+}
@@ -0,0 +1,87 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+func newNoise(w, h int) *image.Paletted {
+	rng := rand.New(rand.NewSource(1))
+	im := image.NewPaletted(image.Rect(0, 0, w, h), palettes.PICO8)
+	for i := range im.Pix {
+		im.Pix[i] = uint8(rng.Intn(len(palettes.PICO8)))
+	}
+	return im
+}
+
+// newStructured draws a few rectangles of color, like a simple picture.
+func newStructured(w, h int) *image.Paletted {
+	im := image.NewPaletted(image.Rect(0, 0, w, h), palettes.PICO8)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			switch {
+			case y > h*2/3:
+				im.Set(x, y, palettes.PICO8_GREEN)
+			case x > w/4 && x < w/2 && y > h/3:
+				im.Set(x, y, palettes.PICO8_BROWN)
+			case (x/3+y/2)%5 == 0:
+				im.Set(x, y, palettes.PICO8_WHITE)
+			case y < h/3:
+				im.Set(x, y, palettes.PICO8_BLUE)
+			}
+		}
+	}
+	return im
+}
+
+var complexitytests = []struct {
+	name   string
+	rating Rating
+}{
+	{"entropy", RateEntropy},
+	{"block-entropy", RateBlockEntropy},
+	{"flate-size", RateFlateSize},
+	{"png-size", RatePNGSize},
+}
+
+func TestComplexityRatings(t *testing.T) {
+	flat := image.NewPaletted(image.Rect(0, 0, 64, 64), palettes.PICO8)
+	noise := newNoise(64, 64)
+	structured := newStructured(64, 64)
+	for _, tt := range complexitytests {
+		f, s, n := tt.rating(flat), tt.rating(structured), tt.rating(noise)
+		if f != 0 {
+			t.Errorf("%s(flat) => %f, expected 0", tt.name, f)
+		}
+		if n < 0.9 || n > 1 {
+			t.Errorf("%s(noise) => %f, expected about 1", tt.name, n)
+		}
+		if s <= f || s >= n {
+			t.Errorf("%s(structured) => %f, expected between flat %f and noise %f", tt.name, s, f, n)
+		}
+	}
+}
+
+func TestRateGoldilocks(t *testing.T) {
+	flat := RateGoldilocks(image.NewPaletted(image.Rect(0, 0, 64, 64), palettes.PICO8))
+	noise := RateGoldilocks(newNoise(64, 64))
+	structured := RateGoldilocks(newStructured(64, 64))
+	if structured <= flat || structured <= noise {
+		t.Errorf("RateGoldilocks(structured) => %f, expected more than flat %f and noise %f", structured, flat, noise)
+	}
+}
+
+func BenchmarkRateFlateSize(b *testing.B) {
+	im := newStructured(89, 64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		RateFlateSize(im)
+	}
+}
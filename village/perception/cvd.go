@@ -0,0 +1,222 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// MinColorDifference is the distance in OKLab below which neighboring colors
+// are hard to tell apart.
+const MinColorDifference = 0.05
+
+// Deficiency is a kind of color vision deficiency.
+type Deficiency int
+
+const (
+	Protanopia Deficiency = iota
+	Deuteranopia
+	Tritanopia
+)
+
+// Deficiencies are all the kinds of Deficiency.
+var Deficiencies = []Deficiency{Protanopia, Deuteranopia, Tritanopia}
+
+func (d Deficiency) String() string {
+	switch d {
+	case Protanopia:
+		return "protanopia"
+	case Deuteranopia:
+		return "deuteranopia"
+	case Tritanopia:
+		return "tritanopia"
+	}
+	return "unknown"
+}
+
+// cvdMatrices simulate each Deficiency at full severity in linear RGB.
+//
+// See: Machado, Oliveira and Fernandes, "A Physiologically-based Model for
+// Simulation of Color Vision Deficiency", 2009.
+// http://www.inf.ufrgs.br/~oliveira/pubs_files/CVD_Simulation/CVD_Simulation.html
+var cvdMatrices = map[Deficiency][3][3]float64{
+	Protanopia: {
+		{0.152286, 1.052583, -0.204868},
+		{0.114503, 0.786281, 0.099216},
+		{-0.003882, -0.048116, 1.051998},
+	},
+	Deuteranopia: {
+		{0.367322, 0.860646, -0.227968},
+		{0.280085, 0.672501, 0.047413},
+		{-0.011820, 0.042940, 0.968881},
+	},
+	Tritanopia: {
+		{1.255528, -0.076749, -0.178779},
+		{-0.078411, 0.930809, 0.147602},
+		{0.004733, 0.691367, 0.303900},
+	},
+}
+
+// delinearize converts a channel in linear light to sRGB from 0 to 1.
+func delinearize(c float64) float64 {
+	c = math.Max(0.0, math.Min(c, 1.0))
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return 1.055*math.Pow(c, 1/2.4) - 0.055
+}
+
+// SimulateColor returns how a color looks to someone with a deficiency.
+func SimulateColor(c color.Color, d Deficiency) color.Color {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	rgb := [3]float64{
+		linearize(float64(n.R) / 255),
+		linearize(float64(n.G) / 255),
+		linearize(float64(n.B) / 255),
+	}
+	m := cvdMatrices[d]
+	var out [3]uint8
+	for i := range out {
+		v := m[i][0]*rgb[0] + m[i][1]*rgb[1] + m[i][2]*rgb[2]
+		out[i] = uint8(math.Floor(delinearize(v)*255 + 0.5))
+	}
+	return color.NRGBA{out[0], out[1], out[2], n.A}
+}
+
+// Simulate returns how an image looks to someone with a deficiency.
+func Simulate(im image.Image, d Deficiency) *image.NRGBA {
+	b := im.Bounds()
+	out := image.NewNRGBA(b)
+	sim := make(map[color.Color]color.Color)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := im.At(x, y)
+			s, ok := sim[c]
+			if !ok {
+				s = SimulateColor(c, d)
+				sim[c] = s
+			}
+			out.Set(x, y, s)
+		}
+	}
+	return out
+}
+
+// colorDifference is the distance between two colors in OKLab.
+func colorDifference(a, b color.Color) float64 {
+	la, lb := ToOKLCh(a), ToOKLCh(b)
+	ha, hb := la.H*math.Pi/180, lb.H*math.Pi/180
+	return math.Sqrt(math.Pow(la.L-lb.L, 2) +
+		math.Pow(la.C*math.Cos(ha)-lb.C*math.Cos(hb), 2) +
+		math.Pow(la.C*math.Sin(ha)-lb.C*math.Sin(hb), 2))
+}
+
+// Struct ColorPair is two colors which meet in an image, and how many times.
+type ColorPair struct {
+	A, B  color.Color
+	Edges int
+}
+
+// neighborPairs counts the pairs of different colors which meet in an image,
+// along with the total number of such edges.
+func neighborPairs(im image.Image) ([]ColorPair, int) {
+	b := im.Bounds()
+	type key struct{ a, b color.Color }
+	idx := make(map[key]int)
+	var pairs []ColorPair
+	total := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := im.At(x, y)
+			for _, n := range []image.Point{{x + 1, y}, {x, y + 1}} {
+				if !n.In(b) {
+					continue
+				}
+				nc := im.At(n.X, n.Y)
+				if sameColor(c, nc) {
+					continue
+				}
+				total++
+				k := key{c, nc}
+				i, ok := idx[k]
+				if !ok {
+					i, ok = idx[key{nc, c}]
+				}
+				if !ok {
+					i = len(pairs)
+					idx[k] = i
+					pairs = append(pairs, ColorPair{A: c, B: nc})
+				}
+				pairs[i].Edges++
+			}
+		}
+	}
+	return pairs, total
+}
+
+// ConfusedPairs returns the pairs of neighboring colors which are easy to
+// tell apart, but not for someone with a deficiency, most edges first.
+func ConfusedPairs(im image.Image, d Deficiency) []ColorPair {
+	pairs, _ := neighborPairs(im)
+	return confused(pairs, d)
+}
+
+func confused(pairs []ColorPair, d Deficiency) []ColorPair {
+	var out []ColorPair
+	for _, p := range pairs {
+		if colorDifference(p.A, p.B) < MinColorDifference {
+			continue
+		}
+		if colorDifference(SimulateColor(p.A, d), SimulateColor(p.B, d)) < MinColorDifference {
+			out = append(out, p)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Edges > out[j].Edges
+	})
+	return out
+}
+
+// RateDeficiency rates an image by the share of edges between regions which
+// stay easy to tell apart for someone with a deficiency.
+func RateDeficiency(im image.Image, d Deficiency) float64 {
+	pairs, total := neighborPairs(im)
+	if total == 0 {
+		return 1.0
+	}
+	lost := 0
+	for _, p := range confused(pairs, d) {
+		lost += p.Edges
+	}
+	return 1.0 - float64(lost)/float64(total)
+}
+
+// NewDeficiencyRating creates a rating function for a deficiency.
+func NewDeficiencyRating(d Deficiency) Rating {
+	return func(im image.Image) float64 {
+		return RateDeficiency(im, d)
+	}
+}
+
+// RateAccessible rates an image by whichever of the Deficiencies loses the
+// most edges between regions.
+func RateAccessible(im image.Image) float64 {
+	pairs, total := neighborPairs(im)
+	if total == 0 {
+		return 1.0
+	}
+	worst := 1.0
+	for _, d := range Deficiencies {
+		lost := 0
+		for _, p := range confused(pairs, d) {
+			lost += p.Edges
+		}
+		worst = math.Min(worst, 1.0-float64(lost)/float64(total))
+	}
+	return worst
+}
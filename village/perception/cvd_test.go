@@ -0,0 +1,63 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+func TestSimulateColorGrays(t *testing.T) {
+	// Grays look the same with any deficiency.
+	for _, d := range Deficiencies {
+		for _, c := range []color.NRGBA{{0, 0, 0, 255}, {128, 128, 128, 255}, {255, 255, 255, 255}} {
+			if got := SimulateColor(c, d); got != c {
+				t.Errorf("SimulateColor(%v, %s) => %v, expected %v", c, d, got, c)
+			}
+		}
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	im := newStripes([]color.Color{palettes.PICO8_ORANGE, palettes.PICO8_GREEN}, []int{2, 2})
+	sim := Simulate(im, Deuteranopia)
+	if sim.Bounds() != im.Bounds() {
+		t.Fatalf("Simulate(im).Bounds() => %v, expected %v", sim.Bounds(), im.Bounds())
+	}
+	if got, want := sim.At(0, 0), SimulateColor(palettes.PICO8_ORANGE, Deuteranopia); got != want {
+		t.Errorf("Simulate(im).At(0, 0) => %v, expected %v", got, want)
+	}
+}
+
+func TestRateDeficiency(t *testing.T) {
+	orangeGreen := newStripes([]color.Color{palettes.PICO8_ORANGE, palettes.PICO8_GREEN}, []int{5, 5})
+	blackWhite := newStripes([]color.Color{palettes.PICO8_BLACK, palettes.PICO8_WHITE}, []int{5, 5})
+	flat := newStripes([]color.Color{palettes.PICO8_BLACK}, []int{5})
+
+	if got := RateDeficiency(orangeGreen, Deuteranopia); got != 0 {
+		t.Errorf("RateDeficiency(orange and green, deuteranopia) => %f, expected 0", got)
+	}
+	if got := RateDeficiency(orangeGreen, Tritanopia); got != 1 {
+		t.Errorf("RateDeficiency(orange and green, tritanopia) => %f, expected 1", got)
+	}
+	if got := RateAccessible(orangeGreen); got != 0 {
+		t.Errorf("RateAccessible(orange and green) => %f, expected 0", got)
+	}
+	for _, d := range Deficiencies {
+		if got := RateDeficiency(blackWhite, d); got != 1 {
+			t.Errorf("RateDeficiency(black and white, %s) => %f, expected 1", d, got)
+		}
+		if got := RateDeficiency(flat, d); got != 1 {
+			t.Errorf("RateDeficiency(flat, %s) => %f, expected 1", d, got)
+		}
+	}
+
+	pairs := ConfusedPairs(orangeGreen, Deuteranopia)
+	if len(pairs) != 1 || pairs[0].Edges != 10 {
+		t.Errorf("ConfusedPairs(orange and green, deuteranopia) => %v, expected one pair with 10 edges", pairs)
+	}
+}
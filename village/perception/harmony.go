@@ -0,0 +1,243 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+const (
+	// MinChroma is the OKLCh chroma below which a color counts as a gray
+	// and has no hue.
+	MinChroma = 0.04
+	// HueTolerance is how far, in degrees, a hue may be from a hue of a
+	// Scheme and still fit it.
+	HueTolerance = 15.0
+	// MinHueShare is the share of the colorful pixels a hue of a Scheme needs
+	// to count as used.
+	MinHueShare = 0.05
+	// ReadableContrast is the difference in OKLab lightness between
+	// neighboring regions which reads clearly.
+	ReadableContrast = 0.2
+	// WarmHue is the OKLCh hue, in degrees, between red and yellow which is
+	// warmest. Hues more than 90 degrees from it are cool.
+	WarmHue = 60.0
+	// DominantTemperature is the ideal share of the colorful pixels with the
+	// more common temperature.
+	DominantTemperature = 0.7
+)
+
+// Struct OKLCh is a color in the OKLCh color space, the polar form of OKLab.
+//
+// L is lightness, from 0 to 1. C is chroma, from 0 to about 0.37. H is hue,
+// in degrees.
+//
+// See: https://bottosson.github.io/posts/oklab/
+type OKLCh struct {
+	L, C, H float64
+}
+
+// linearize converts an sRGB channel from 0 to 1 to linear light.
+func linearize(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// ToOKLCh converts a color to OKLCh, ignoring alpha.
+func ToOKLCh(c color.Color) OKLCh {
+	r16, g16, b16, _ := c.RGBA()
+	r := linearize(float64(r16) / 65535)
+	g := linearize(float64(g16) / 65535)
+	b := linearize(float64(b16) / 65535)
+
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+
+	L := 0.2104542553*l + 0.7936177850*m - 0.0040720468*s
+	A := 1.9779984951*l - 2.4285922050*m + 0.4505937099*s
+	B := 0.0259040371*l + 0.7827717662*m - 0.8086757660*s
+
+	h := math.Atan2(B, A) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return OKLCh{L: L, C: math.Hypot(A, B), H: h}
+}
+
+// hueDist is the angle, in degrees, between two hues.
+func hueDist(a, b float64) float64 {
+	return math.Abs(math.Mod(a-b+540, 360) - 180)
+}
+
+// Struct hueMass is how much of an image is a hue.
+type hueMass struct {
+	hue  float64
+	mass float64
+}
+
+// hues returns the hues of the colorful pixels in an image, with the share of
+// the colorful pixels each has.
+func hues(im image.Image) []hueMass {
+	var hs []hueMass
+	total := 0.0
+	hist := cachedHistogram(im)
+	for i, c := range hist.Colors {
+		n := hist.Counts[i]
+		if n == 0 {
+			continue
+		}
+		lch := ToOKLCh(c)
+		if lch.C < MinChroma {
+			continue
+		}
+		hs = append(hs, hueMass{hue: lch.H, mass: float64(n)})
+		total += float64(n)
+	}
+	for i := range hs {
+		hs[i].mass /= total
+	}
+	// Sort, so the ratings sum the hues in the same order every time.
+	sort.Slice(hs, func(i, j int) bool {
+		if hs[i].hue != hs[j].hue {
+			return hs[i].hue < hs[j].hue
+		}
+		return hs[i].mass < hs[j].mass
+	})
+	return hs
+}
+
+// Struct Scheme is a color scheme, as hues relative to a base hue.
+type Scheme struct {
+	Name string
+	Hues []float64
+}
+
+var (
+	Complementary      = Scheme{"complementary", []float64{0, 180}}
+	Analogous          = Scheme{"analogous", []float64{-30, 0, 30}}
+	Triadic            = Scheme{"triadic", []float64{0, 120, 240}}
+	SplitComplementary = Scheme{"split-complementary", []float64{0, 150, 210}}
+)
+
+// Schemes are the color schemes RateHarmony looks for.
+var Schemes = []Scheme{Complementary, Analogous, Triadic, SplitComplementary}
+
+// fitScheme rates how well hues fit a scheme turned to a base hue.
+//
+// It is the share of the hues within HueTolerance of a hue of the scheme,
+// times the share of the hues of the scheme which are used.
+func fitScheme(hs []hueMass, s Scheme, base float64) float64 {
+	fit := 0.0
+	shares := make([]float64, len(s.Hues))
+	for _, h := range hs {
+		best, bestDist := -1, HueTolerance
+		for i, sh := range s.Hues {
+			if d := hueDist(h.hue, base+sh); d <= bestDist {
+				best, bestDist = i, d
+			}
+		}
+		if best >= 0 {
+			fit += h.mass
+			shares[best] += h.mass
+		}
+	}
+	used := 0
+	for _, sh := range shares {
+		if sh >= MinHueShare {
+			used++
+		}
+	}
+	return fit * float64(used) / float64(len(s.Hues))
+}
+
+// RateScheme rates an image by how well its hues fit a color scheme, turned
+// to whichever base hue fits best. Grays are ignored.
+func RateScheme(im image.Image, s Scheme) float64 {
+	hs := hues(im)
+	best := 0.0
+	for base := 0; base < 360; base++ {
+		best = math.Max(best, fitScheme(hs, s, float64(base)))
+	}
+	return best
+}
+
+// NewSchemeRating creates a rating function which desires a color scheme.
+func NewSchemeRating(s Scheme) Rating {
+	return func(im image.Image) float64 {
+		return RateScheme(im, s)
+	}
+}
+
+// RateHarmony rates an image by whichever of the Schemes it fits best.
+func RateHarmony(im image.Image) float64 {
+	best := 0.0
+	for _, s := range Schemes {
+		best = math.Max(best, RateScheme(im, s))
+	}
+	return best
+}
+
+// RateValueContrast rates an image by the difference in lightness where
+// regions of different colors meet, up to ReadableContrast.
+func RateValueContrast(im image.Image) float64 {
+	b := im.Bounds()
+	lightness := make(map[color.Color]float64)
+	light := func(c color.Color) float64 {
+		l, ok := lightness[c]
+		if !ok {
+			l = ToOKLCh(c).L
+			lightness[c] = l
+		}
+		return l
+	}
+	contrast := 0.0
+	edges := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := im.At(x, y)
+			for _, n := range []image.Point{{x + 1, y}, {x, y + 1}} {
+				if !n.In(b) {
+					continue
+				}
+				nc := im.At(n.X, n.Y)
+				if sameColor(c, nc) {
+					continue
+				}
+				edges++
+				contrast += math.Min(math.Abs(light(c)-light(nc))/ReadableContrast, 1.0)
+			}
+		}
+	}
+	if edges == 0 {
+		return 0.0
+	}
+	return contrast / float64(edges)
+}
+
+// RateTemperature rates an image by its balance of warm and cool colors.
+//
+// It is 1 when DominantTemperature of the colorful pixels share a
+// temperature, and 0 when the temperatures are evenly split or all the same.
+func RateTemperature(im image.Image) float64 {
+	hs := hues(im)
+	if len(hs) == 0 {
+		return 0.0
+	}
+	warm := 0.0
+	for _, h := range hs {
+		if hueDist(h.hue, WarmHue) < 90 {
+			warm += h.mass
+		}
+	}
+	dominant := math.Max(warm, 1-warm)
+	// Rescale from [0.5, 1] to [0, 1].
+	return tent(2*dominant-1, 2*DominantTemperature-1)
+}
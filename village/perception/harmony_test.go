@@ -0,0 +1,90 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+// newStripes draws vertical stripes of each color, as wide as its width.
+func newStripes(cs []color.Color, widths []int) image.Image {
+	w := 0
+	for _, sw := range widths {
+		w += sw
+	}
+	im := image.NewRGBA(image.Rect(0, 0, w, 10))
+	x := 0
+	for i, c := range cs {
+		for sx := 0; sx < widths[i]; sx++ {
+			for y := 0; y < 10; y++ {
+				im.Set(x, y, c)
+			}
+			x++
+		}
+	}
+	return im
+}
+
+func TestToOKLCh(t *testing.T) {
+	white := ToOKLCh(color.RGBA{255, 255, 255, 255})
+	if math.Abs(white.L-1) > 1e-3 || white.C > 1e-3 {
+		t.Errorf("ToOKLCh(white) => %v, expected L 1, C 0", white)
+	}
+	// Red has hue about 29 degrees in OKLCh.
+	red := ToOKLCh(color.RGBA{255, 0, 0, 255})
+	if math.Abs(red.H-29.2) > 0.5 {
+		t.Errorf("ToOKLCh(red).H => %f, expected about 29.2", red.H)
+	}
+}
+
+var sRGBRed = color.RGBA{255, 0, 0, 255}
+var sRGBGreen = color.RGBA{0, 255, 0, 255}
+var sRGBBlue = color.RGBA{0, 0, 255, 255}
+
+var harmonytests = []struct {
+	name   string
+	rating Rating
+	im     image.Image
+	lo, hi float64
+}{
+	{"complementary orange and blue", NewSchemeRating(Complementary),
+		newStripes([]color.Color{palettes.PICO8_ORANGE, palettes.PICO8_BLUE}, []int{5, 5}), 1, 1},
+	{"complementary one hue", NewSchemeRating(Complementary),
+		newStripes([]color.Color{palettes.PICO8_ORANGE}, []int{5}), 0.5, 0.5},
+	{"analogous reds and oranges", NewSchemeRating(Analogous),
+		newStripes([]color.Color{palettes.PICO8_RED, palettes.PICO8_BROWN, palettes.PICO8_ORANGE}, []int{3, 3, 3}), 1, 1},
+	{"analogous orange and blue", NewSchemeRating(Analogous),
+		newStripes([]color.Color{palettes.PICO8_ORANGE, palettes.PICO8_BLUE}, []int{5, 5}), 0, 0.2},
+	{"triadic red, green and blue", NewSchemeRating(Triadic),
+		newStripes([]color.Color{sRGBRed, sRGBGreen, sRGBBlue}, []int{3, 3, 3}), 1, 1},
+	{"harmony grays", RateHarmony,
+		newStripes([]color.Color{palettes.PICO8_BLACK, palettes.PICO8_LIGHT_GRAY}, []int{5, 5}), 0, 0},
+	{"value contrast black and white", RateValueContrast,
+		newStripes([]color.Color{palettes.PICO8_BLACK, palettes.PICO8_WHITE}, []int{5, 5}), 1, 1},
+	{"value contrast dark blue and dark purple", RateValueContrast,
+		newStripes([]color.Color{palettes.PICO8_DARK_BLUE, palettes.PICO8_DARK_PURPLE}, []int{5, 5}), 0.5, 0.7},
+	{"value contrast flat", RateValueContrast,
+		newStripes([]color.Color{palettes.PICO8_WHITE}, []int{5}), 0, 0},
+	{"temperature mostly warm", RateTemperature,
+		newStripes([]color.Color{palettes.PICO8_ORANGE, palettes.PICO8_BLUE}, []int{7, 3}), 1, 1},
+	{"temperature split", RateTemperature,
+		newStripes([]color.Color{palettes.PICO8_ORANGE, palettes.PICO8_BLUE}, []int{5, 5}), 0, 0},
+	{"temperature all warm", RateTemperature,
+		newStripes([]color.Color{palettes.PICO8_ORANGE}, []int{5}), 0, 0},
+}
+
+func TestHarmonyRatings(t *testing.T) {
+	for _, tt := range harmonytests {
+		got := tt.rating(tt.im)
+		if got < tt.lo-1e-9 || got > tt.hi+1e-9 {
+			t.Errorf("%s: rating => %f, expected in [%f, %f]", tt.name, got, tt.lo, tt.hi)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+)
+
+// Struct Histogram counts the pixels of each color in an image.
+//
+// Colors are compared as color.Color values, like map keys, so an
+// image.NRGBA pixel is not the same color as an equal color.RGBA.
+type Histogram struct {
+	// Colors are the distinct colors. For an *image.Paletted, they are its
+	// palette.
+	Colors []color.Color
+	// Counts are the number of pixels of each of the Colors.
+	Counts []int
+	// Total is the number of pixels.
+	Total int
+}
+
+// Count returns the number of pixels of a color.
+func (h *Histogram) Count(c color.Color) int {
+	n := 0
+	for i, hc := range h.Colors {
+		if hc == c {
+			n += h.Counts[i]
+		}
+	}
+	return n
+}
+
+// Fraction returns the fraction of the pixels which are a color.
+func (h *Histogram) Fraction(c color.Color) float64 {
+	if h.Total == 0 {
+		return 0.0
+	}
+	return float64(h.Count(c)) / float64(h.Total)
+}
+
+// Map returns the number of pixels of each color which is in the image.
+func (h *Histogram) Map() map[color.Color]int {
+	m := make(map[color.Color]int)
+	for i, c := range h.Colors {
+		if h.Counts[i] > 0 {
+			m[c] += h.Counts[i]
+		}
+	}
+	return m
+}
+
+// countPaletted counts the pixels of a paletted image by index.
+func countPaletted(im *image.Paletted) *Histogram {
+	h := &Histogram{}
+	h.countPaletted(im)
+	return h
+}
+
+// countPaletted counts the pixels of a paletted image into h, reusing its
+// counts.
+func (h *Histogram) countPaletted(im *image.Paletted) {
+	h.Colors = im.Palette
+	if len(h.Counts) != len(im.Palette) {
+		h.Counts = make([]int, len(im.Palette))
+	}
+	b := im.Bounds()
+	w := b.Dx()
+	// Count into a fixed array, which is faster than bounds checked slices.
+	var cnts [256]int
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := im.PixOffset(b.Min.X, y)
+		for _, p := range im.Pix[i : i+w] {
+			cnts[p]++
+		}
+	}
+	copy(h.Counts, cnts[:])
+	h.Total = w * b.Dy()
+}
+
+// countImage counts the pixels of any image.
+func countImage(im image.Image) *Histogram {
+	m := make(map[color.Color]int)
+	b := im.Bounds()
+	for x := b.Min.X; x < b.Max.X; x++ {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			m[im.At(x, y)] += 1
+		}
+	}
+	h := &Histogram{Total: b.Dx() * b.Dy()}
+	for c, n := range m {
+		h.Colors = append(h.Colors, c)
+		h.Counts = append(h.Counts, n)
+	}
+	return h
+}
+
+// Struct histogramEntry is the histogram of an image when it had some pixels.
+type histogramEntry struct {
+	im      *image.Paletted
+	pix     []uint8
+	palette []color.Color
+	rect    image.Rectangle
+	hist    *Histogram
+}
+
+// fresh is whether the image hasn't changed since the entry was made.
+func (e *histogramEntry) fresh(im *image.Paletted) bool {
+	return e.im == im &&
+		e.rect == im.Rect &&
+		len(e.palette) == len(im.Palette) &&
+		(len(e.palette) == 0 || &e.palette[0] == &im.Palette[0]) &&
+		bytes.Equal(e.pix, im.Pix)
+}
+
+// Struct CountedImage is a paletted image which remembers the histogram of
+// its pixels until they change.
+//
+// Images don't have version numbers, so the version is the pixels
+// themselves. Comparing them is much faster than counting them, and ratings
+// such as a Personality's look at the same version many times. The histogram
+// is kept with the image, not in a cache shared by every image, so that
+// goroutines rating their own images don't wait on each other. A
+// CountedImage is not safe for concurrent use.
+type CountedImage struct {
+	*image.Paletted
+	entry histogramEntry
+}
+
+// histogram returns the histogram of the image, counting it again if its
+// pixels have changed.
+func (c *CountedImage) histogram() *Histogram {
+	e := &c.entry
+	if e.hist != nil && e.fresh(c.Paletted) {
+		return e.hist
+	}
+	if e.hist == nil {
+		e.hist = &Histogram{}
+	}
+	e.im = c.Paletted
+	e.pix = append(e.pix[:0], c.Pix...)
+	e.palette = c.Palette
+	e.rect = c.Rect
+	// Count the new version of the image in place, so that strategies which
+	// paint and undo on a canvas don't allocate.
+	e.hist.countPaletted(c.Paletted)
+	return e.hist
+}
+
+// paletted returns the *image.Paletted which im is, or which it wraps if it
+// is a CountedImage.
+func paletted(im image.Image) (*image.Paletted, bool) {
+	switch im := im.(type) {
+	case *image.Paletted:
+		return im, true
+	case *CountedImage:
+		return im.Paletted, true
+	}
+	return nil, false
+}
+
+// cachedHistogram counts the pixels of each color in an image.
+//
+// Counting an *image.Paletted is fast, and the histogram of a CountedImage
+// is cached until its pixels change. The cached histogram is recounted in
+// place when they do, so the result must not be modified or kept after the
+// image changes.
+func cachedHistogram(im image.Image) *Histogram {
+	switch im := im.(type) {
+	case *CountedImage:
+		return im.histogram()
+	case *image.Paletted:
+		return countPaletted(im)
+	}
+	return countImage(im)
+}
+
+// NewHistogram counts the pixels of each color in an image.
+//
+// Counting an *image.Paletted or a CountedImage is fast. The histogram is a
+// copy, so it doesn't change when the image does.
+func NewHistogram(im image.Image) *Histogram {
+	h := cachedHistogram(im)
+	return &Histogram{
+		Colors: append([]color.Color(nil), h.Colors...),
+		Counts: append([]int(nil), h.Counts...),
+		Total:  h.Total,
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"image"
+	"image/draw"
+	"reflect"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+func TestNewHistogram(t *testing.T) {
+	r := image.Rectangle{image.Point{0, 0}, image.Point{10, 10}}
+	im := image.NewPaletted(r, palettes.PICO8)
+	setProp(im, palettes.PICO8_PINK, 30)
+
+	hist := NewHistogram(im)
+	if got := hist.Count(palettes.PICO8_PINK); got != 30 {
+		t.Errorf("NewHistogram(im).Count(pink) => %d, expected 30", got)
+	}
+	if got := hist.Fraction(palettes.PICO8_BLACK); got != 0.7 {
+		t.Errorf("NewHistogram(im).Fraction(black) => %f, expected 0.7", got)
+	}
+
+	// Other kinds of images count the same colors.
+	rgba := image.NewRGBA(r)
+	draw.Draw(rgba, r, im, r.Min, draw.Src)
+	if got, want := countImage(rgba).Map(), hist.Map(); len(got) != len(want) {
+		t.Errorf("countImage(rgba).Map() => %v, expected %v", got, want)
+	}
+
+	// Changing the image changes its histogram.
+	im.Set(9, 9, palettes.PICO8_PINK)
+	if got := NewHistogram(im).Count(palettes.PICO8_PINK); got != 31 {
+		t.Errorf("NewHistogram(im).Count(pink) after Set => %d, expected 31", got)
+	}
+	im.Set(9, 9, palettes.PICO8_BLACK)
+	if got := NewHistogram(im).Count(palettes.PICO8_PINK); got != 30 {
+		t.Errorf("NewHistogram(im).Count(pink) after undo => %d, expected 30", got)
+	}
+
+	// A histogram which was kept doesn't change with the image.
+	counted := &CountedImage{Paletted: im}
+	hist = NewHistogram(counted)
+	im.Set(9, 9, palettes.PICO8_PINK)
+	cachedHistogram(counted)
+	if got := hist.Count(palettes.PICO8_PINK); got != 30 {
+		t.Errorf("hist.Count(pink) after Set => %d, expected 30", got)
+	}
+}
+
+func TestCountedImage(t *testing.T) {
+	r := image.Rectangle{image.Point{0, 0}, image.Point{10, 10}}
+	im := image.NewPaletted(r, palettes.PICO8)
+	setProp(im, palettes.PICO8_PINK, 30)
+	counted := &CountedImage{Paletted: im}
+
+	hist := cachedHistogram(counted)
+	if got := cachedHistogram(counted); got != hist || got.Count(palettes.PICO8_PINK) != 30 {
+		t.Errorf("cachedHistogram(counted) => %v, expected the same histogram with 30 pink", got)
+	}
+
+	// Changing the pixels counts them again.
+	im.Set(9, 9, palettes.PICO8_PINK)
+	if got := cachedHistogram(counted).Count(palettes.PICO8_PINK); got != 31 {
+		t.Errorf("cachedHistogram(counted).Count(pink) after Set => %d, expected 31", got)
+	}
+
+	// So does wrapping another image.
+	counted.Paletted = image.NewPaletted(r, palettes.PICO8)
+	if got := cachedHistogram(counted).Count(palettes.PICO8_PINK); got != 0 {
+		t.Errorf("cachedHistogram(counted).Count(pink) of a new image => %d, expected 0", got)
+	}
+
+	// Ratings see the wrapped image.
+	if got, want := RatePNGSize(counted), RatePNGSize(counted.Paletted); got != want {
+		t.Errorf("RatePNGSize(counted) => %f, expected %f", got, want)
+	}
+}
+
+func TestNewHistogramSubImage(t *testing.T) {
+	r := image.Rectangle{image.Point{0, 0}, image.Point{10, 10}}
+	im := image.NewPaletted(r, palettes.PICO8)
+	setProp(im, palettes.PICO8_PINK, 30)
+	sub := im.SubImage(image.Rect(0, 2, 10, 4)).(*image.Paletted)
+	want := map[string]int{"pink": 6, "black": 14}
+	got := make(map[string]int)
+	for c, n := range NewHistogram(sub).Map() {
+		for i, pc := range palettes.PICO8 {
+			if pc == c {
+				got[palettes.PICO8_NAMES[i]] = n
+			}
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewHistogram(sub) => %v, expected %v", got, want)
+	}
+}
+
+func BenchmarkCountPaletted(b *testing.B) {
+	im := newStructured(89, 64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		countPaletted(im)
+	}
+}
+
+func BenchmarkCountImage(b *testing.B) {
+	im := newStructured(89, 64)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		countImage(im)
+	}
+}
+
+func BenchmarkCountedImage(b *testing.B) {
+	im := &CountedImage{Paletted: newStructured(89, 64)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cachedHistogram(im)
+	}
+}
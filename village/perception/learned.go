@@ -0,0 +1,171 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+	"sort"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+// FeatureNames are the features a Learned rating can use: the fraction of
+// each PICO-8 color, by palettes.PICO8_NAMES, then each of the Compositions.
+func FeatureNames() []string {
+	names := append([]string{}, palettes.PICO8_NAMES...)
+	var cs []string
+	for n := range Compositions {
+		cs = append(cs, n)
+	}
+	sort.Strings(cs)
+	return append(names, cs...)
+}
+
+// Features describes an image by the named features.
+func Features(im image.Image, names []string) ([]float64, error) {
+	b := im.Bounds()
+	pxls := b.Dx() * b.Dy()
+	hist := cachedHistogram(im)
+	f := make([]float64, len(names))
+	for i, n := range names {
+		if r, ok := Compositions[n]; ok {
+			f[i] = r(im)
+			continue
+		}
+		found := false
+		for c, cn := range palettes.PICO8_NAMES {
+			if cn == n {
+				if pxls > 0 {
+					f[i] = float64(hist.Count(palettes.PICO8[c])) / float64(pxls)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Unknown feature %q", n)
+		}
+	}
+	return f, nil
+}
+
+// Struct Comparison is a human preference between the features of two
+// images.
+type Comparison struct {
+	Winner []float64
+	Loser  []float64
+}
+
+// Struct Learned is a rating fitted to human preferences.
+//
+// It is a Bradley–Terry model: the probability that image a is preferred to
+// image b is sigmoid(score(a) - score(b)), where the score is linear in the
+// features. That is logistic regression on the difference of the features.
+type Learned struct {
+	Features []string  `json:"features"`
+	Weights  []float64 `json:"weights"`
+}
+
+func sigmoid(x float64) float64 {
+	return 1.0 / (1.0 + math.Exp(-x))
+}
+
+func dot(w, f []float64) float64 {
+	s := 0.0
+	for i, x := range f {
+		s += w[i] * x
+	}
+	return s
+}
+
+// FitLearned fits weights with gradient descent on the log-likelihood of the
+// comparisons, with L2 regularization.
+func FitLearned(features []string, cs []Comparison, epochs int, rate, l2 float64) *Learned {
+	l := &Learned{Features: features, Weights: make([]float64, len(features))}
+	if len(cs) == 0 {
+		return l
+	}
+	grad := make([]float64, len(features))
+	for e := 0; e < epochs; e++ {
+		for i := range grad {
+			grad[i] = -l2 * l.Weights[i]
+		}
+		for _, c := range cs {
+			d := make([]float64, len(features))
+			for i := range d {
+				d[i] = c.Winner[i] - c.Loser[i]
+			}
+			// The gradient of log(sigmoid(w . d)).
+			g := 1.0 - sigmoid(dot(l.Weights, d))
+			for i, x := range d {
+				grad[i] += g * x / float64(len(cs))
+			}
+		}
+		for i := range l.Weights {
+			l.Weights[i] += rate * grad[i]
+		}
+	}
+	return l
+}
+
+// Accuracy is the fraction of comparisons in which the winner scores higher.
+func (l *Learned) Accuracy(cs []Comparison) float64 {
+	if len(cs) == 0 {
+		return 0
+	}
+	right := 0
+	for _, c := range cs {
+		if dot(l.Weights, c.Winner) > dot(l.Weights, c.Loser) {
+			right++
+		}
+	}
+	return float64(right) / float64(len(cs))
+}
+
+// Rate rates an image from 0 to 1 as sigmoid(score), which keeps the order
+// of the Bradley–Terry scores.
+func (l *Learned) Rate(im image.Image) float64 {
+	f, err := Features(im, l.Features)
+	if err != nil {
+		// LoadLearned checks the features, so this is a programming error.
+		panic(err)
+	}
+	return sigmoid(dot(l.Weights, f))
+}
+
+// LoadLearned reads a learned rating from a JSON file.
+func LoadLearned(path string) (*Learned, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", path, err)
+	}
+	l := &Learned{}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, fmt.Errorf("Error decoding %s: %s", path, err)
+	}
+	if len(l.Weights) != len(l.Features) {
+		return nil, fmt.Errorf("Error in %s: got %d weights for %d features", path, len(l.Weights), len(l.Features))
+	}
+	if _, err := Features(image.NewGray(image.Rect(0, 0, 1, 1)), l.Features); err != nil {
+		return nil, fmt.Errorf("Error in %s: %s", path, err)
+	}
+	return l, nil
+}
+
+// Save writes a learned rating to a JSON file.
+func (l *Learned) Save(path string) error {
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding %s: %s", path, err)
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("Error writing %s: %s", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"image"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+func TestFeatures(t *testing.T) {
+	r := image.Rectangle{image.Point{0, 0}, image.Point{10, 10}}
+	im := image.NewPaletted(r, palettes.PICO8)
+	setProp(im, palettes.PICO8_PINK, 30)
+
+	got, err := Features(im, []string{"pink", "black"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{0.3, 0.7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Features(im, pink, black) => %v, expected %v", got, want)
+	}
+	if _, err := Features(im, []string{"plaid"}); err == nil {
+		t.Error("Expected error for unknown feature.")
+	}
+	if n := len(FeatureNames()); n != len(palettes.PICO8)+len(Compositions) {
+		t.Errorf("len(FeatureNames()) => %d, expected %d", n, len(palettes.PICO8)+len(Compositions))
+	}
+}
+
+func TestFitLearned(t *testing.T) {
+	// Humans prefer whichever image has more pink.
+	rng := rand.New(rand.NewSource(1))
+	features := []string{"pink", "black"}
+	var cs []Comparison
+	for i := 0; i < 100; i++ {
+		a, b := rng.Float64(), rng.Float64()
+		if a < b {
+			a, b = b, a
+		}
+		cs = append(cs, Comparison{Winner: []float64{a, 1 - a}, Loser: []float64{b, 1 - b}})
+	}
+
+	l := FitLearned(features, cs, 500, 1.0, 0.001)
+
+	if l.Weights[0] <= l.Weights[1] {
+		t.Errorf("FitLearned(...).Weights => %v, expected pink to outweigh black", l.Weights)
+	}
+	if acc := l.Accuracy(cs); acc < 0.99 {
+		t.Errorf("Accuracy => %f, expected about 1.0", acc)
+	}
+
+	r := image.Rectangle{image.Point{0, 0}, image.Point{10, 10}}
+	pink := image.NewPaletted(r, palettes.PICO8)
+	setProp(pink, palettes.PICO8_PINK, 90)
+	black := image.NewPaletted(r, palettes.PICO8)
+	if l.Rate(pink) <= l.Rate(black) {
+		t.Errorf("Rate(pink) => %f, expected more than Rate(black) => %f", l.Rate(pink), l.Rate(black))
+	}
+}
+
+func TestLearnedSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "perception")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "learned.json")
+
+	l := &Learned{Features: []string{"pink", "tl-corners"}, Weights: []float64{0.5, -1}}
+	if err := l.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadLearned(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, l) {
+		t.Errorf("LoadLearned(Save(l)) => %#v, expected %#v", got, l)
+	}
+
+	bad := &Learned{Features: []string{"plaid"}, Weights: []float64{1}}
+	if err := bad.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLearned(path); err == nil {
+		t.Error("Expected error loading unknown feature.")
+	}
+}
@@ -40,28 +40,45 @@ const (
 // The return value *should* be in [0, 1].
 type Rating func(im image.Image) float64
 
+// CountColors returns the number of pixels of each color in an image.
+//
+// NewHistogram is faster for paletted images.
 func CountColors(im image.Image) map[color.Color]int {
-	m := make(map[color.Color]int)
+	return cachedHistogram(im).Map()
+}
+
+// ToPalette maps every pixel of an image to the nearest color in a palette.
+//
+// The ratings count colors exactly, so use it for images which weren't drawn
+// with the palette. It also returns how many pixels weren't a palette color.
+func ToPalette(im image.Image, pal color.Palette) (*image.Paletted, int) {
 	b := im.Bounds()
-	w := b.Max.X - b.Min.X
-	h := b.Max.Y - b.Min.Y
-	pxls := w * h
-	if pxls == 0 {
-		return m
-	}
+	out := image.NewPaletted(b, pal)
+	off := 0
 	for x := b.Min.X; x < b.Max.X; x++ {
 		for y := b.Min.Y; y < b.Max.Y; y++ {
-			m[im.At(x, y)] += 1
+			c := im.At(x, y)
+			i := pal.Index(c)
+			r1, g1, b1, a1 := c.RGBA()
+			r2, g2, b2, a2 := pal[i].RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				off++
+			}
+			out.SetColorIndex(x, y, uint8(i))
 		}
 	}
-	return m
+	return out, off
 }
 
 // RateImage rates an image from 0 to 1 based on perception of color.
 //
 // Value is 0 at the endpoints and 1 at the ideal value.
 func RateImage(pxls, cnt int, ideal float64) float64 {
-	x := float64(cnt) / float64(pxls)
+	return tent(float64(cnt)/float64(pxls), ideal)
+}
+
+// tent rates x in [0, 1] as 1 at ideal, falling linearly to 0 at 0 and 1.
+func tent(x, ideal float64) float64 {
 	m := 1.0 / ideal
 	b := 0.0
 	// If ideal is exactly 0, make sure the line slopes down
@@ -79,7 +96,7 @@ func NewRating(ideal float64, c color.Color) Rating {
 		w := b.Max.X - b.Min.X
 		h := b.Max.Y - b.Min.Y
 		pxls := w * h
-		cnt := CountColors(im)[c]
+		cnt := cachedHistogram(im).Count(c)
 		return RateImage(pxls, cnt, ideal)
 	}
 	return r
@@ -91,55 +108,76 @@ func RateBlack(im image.Image) float64 {
 	w := b.Max.X - b.Min.X
 	h := b.Max.Y - b.Min.Y
 	pxls := w * h
-	cnt := CountColors(im)[palettes.PICO8_BLACK]
+	cnt := cachedHistogram(im).Count(palettes.PICO8_BLACK)
 	return RateImage(pxls, cnt, IdealBlack)
 }
 
-// RateWholeImage rates an image according to predefined "interests".
-func RateWholeImage(im image.Image) float64 {
+// Interests are the ideal amount of each color in the PICO8 palette.
+//
+// They are in the same order as palettes.PICO8.
+type Interests []float64
+
+// DefaultInterests are the predefined "interests" used by RateWholeImage.
+var DefaultInterests = Interests{
+	IdealBlack,
+	IdealDarkBlue,
+	IdealDarkPurple,
+	IdealDarkGreen,
+	IdealBrown,
+	IdealDarkGray,
+	IdealLightGray,
+	IdealWhite,
+	IdealRed,
+	IdealOrange,
+	IdealYellow,
+	IdealGreen,
+	IdealBlue,
+	IdealIndigo,
+	IdealPink,
+	IdealPeach,
+}
+
+// Rate rates an image according to the interests.
+func (in Interests) Rate(im image.Image) float64 {
 	b := im.Bounds()
 	w := b.Max.X - b.Min.X
 	h := b.Max.Y - b.Min.Y
 	pxls := w * h
-	cnts := CountColors(im)
+	hist := cachedHistogram(im)
 	rt := 0.0
-	r := RateImage(pxls, cnts[palettes.PICO8_BLACK], IdealBlack)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_DARK_BLUE], IdealDarkBlue)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_DARK_PURPLE], IdealDarkPurple)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_DARK_GREEN], IdealDarkGreen)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_BROWN], IdealBrown)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_DARK_GRAY], IdealDarkGray)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_LIGHT_GRAY], IdealLightGray)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_WHITE], IdealWhite)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_RED], IdealRed)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_ORANGE], IdealOrange)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_YELLOW], IdealYellow)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_GREEN], IdealGreen)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_BLUE], IdealBlue)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_INDIGO], IdealIndigo)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_PINK], IdealPink)
-	rt += r
-	r = RateImage(pxls, cnts[palettes.PICO8_PEACH], IdealPeach)
-	rt += r
-	rt /= 16.0
-
+	for i, ideal := range in {
+		rt += RateImage(pxls, hist.Count(palettes.PICO8[i]), ideal)
+	}
+	rt /= float64(len(in))
 	return rt
 }
 
+// Drift moves interests toward the colors used in an image.
+//
+// A rate of 0 keeps the interests the same, and a rate of 1 makes the amount
+// of each color in the image ideal.
+func (in Interests) Drift(im image.Image, rate float64) Interests {
+	b := im.Bounds()
+	w := b.Max.X - b.Min.X
+	h := b.Max.Y - b.Min.Y
+	pxls := w * h
+	hist := cachedHistogram(im)
+	out := make(Interests, len(in))
+	for i, ideal := range in {
+		x := 0.0
+		if pxls > 0 {
+			x = float64(hist.Count(palettes.PICO8[i])) / float64(pxls)
+		}
+		out[i] = ideal + rate*(x-ideal)
+	}
+	return out
+}
+
+// RateWholeImage rates an image according to predefined "interests".
+func RateWholeImage(im image.Image) float64 {
+	return DefaultInterests.Rate(im)
+}
+
 // colorDist calculates the distance between two colors.
 func colorDist(a, b color.Color) float64 {
 	r1d, g1d, b1d, _ := a.RGBA()
@@ -194,6 +232,9 @@ func countTLCorners(im image.Image) float64 {
 	w := b.Max.X - b.Min.X
 	h := b.Max.Y - b.Min.Y
 	pxls := w * h
+	if pxls == 0 {
+		return 0.0
+	}
 	// If x, y is a corner, then just to the right and just below cannot be corners.
 	maxCorners := float64(pxls) / 3.0
 	corners := 0.0
@@ -204,3 +245,50 @@ func countTLCorners(im image.Image) float64 {
 	}
 	return corners / maxCorners
 }
+
+// RateTLCorners rates an image by how many top-left corners it has.
+func RateTLCorners(im image.Image) float64 {
+	return math.Min(countTLCorners(im), 1.0)
+}
+
+// Compositions are ratings of how an image is arranged, by name.
+var Compositions = map[string]Rating{
+	"tl-corners":          RateTLCorners,
+	"orphans":             RateOrphans,
+	"jaggies":             RateJaggies,
+	"banding":             RateBanding,
+	"pillow-shading":      RatePillowShading,
+	"noise":               RateNoise,
+	"outlines":            RateOutlines,
+	"light-direction":     RateLightDirection,
+	"entropy":             RateEntropy,
+	"block-entropy":       RateBlockEntropy,
+	"flate-size":          RateFlateSize,
+	"png-size":            RatePNGSize,
+	"goldilocks":          RateGoldilocks,
+	"harmony":             RateHarmony,
+	"complementary":       NewSchemeRating(Complementary),
+	"analogous":           NewSchemeRating(Analogous),
+	"triadic":             NewSchemeRating(Triadic),
+	"split-complementary": NewSchemeRating(SplitComplementary),
+	"value-contrast":      RateValueContrast,
+	"temperature":         RateTemperature,
+	"cvd-protanopia":      NewDeficiencyRating(Protanopia),
+	"cvd-deuteranopia":    NewDeficiencyRating(Deuteranopia),
+	"cvd-tritanopia":      NewDeficiencyRating(Tritanopia),
+	"accessible":          RateAccessible,
+}
+
+// Ratings are all the ratings which can be chosen by name.
+//
+// It includes the Compositions.
+var Ratings = map[string]Rating{
+	"whole": RateWholeImage,
+	"black": RateBlack,
+}
+
+func init() {
+	for n, r := range Compositions {
+		Ratings[n] = r
+	}
+}
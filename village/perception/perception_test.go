@@ -43,7 +43,7 @@ func TestCountColors(t *testing.T) {
 		got := CountColors(im)
 
 		if got[tt.clr] != tt.cnt {
-			t.Errorf("CountColors(im)[%v] => %f, but expected %f", tt.clr, got, tt.cnt)
+			t.Errorf("CountColors(im)[%v] => %d, but expected %d", tt.clr, got[tt.clr], tt.cnt)
 		}
 	}
 }
@@ -128,3 +128,57 @@ func TestColorDist(t *testing.T) {
 		}
 	}
 }
+
+func TestInterestsRate(t *testing.T) {
+	r := image.Rectangle{image.Point{0, 0}, image.Point{10, 10}}
+	im := image.NewPaletted(r, palettes.PICO8)
+	setProp(im, palettes.PICO8_PINK, 73)
+
+	in := make(Interests, len(palettes.PICO8))
+	in[0] = 0.27
+	in[14] = 0.73
+	got := in.Rate(im)
+
+	// Black and pink are ideal. The other colors are absent and want none.
+	if math.Abs(got-1.0) > 0.001 {
+		t.Errorf("Interests.Rate(im[73%% pink]) => %f, but expected 1.0", got)
+	}
+}
+
+func TestInterestsDrift(t *testing.T) {
+	r := image.Rectangle{image.Point{0, 0}, image.Point{10, 10}}
+	im := image.NewPaletted(r, palettes.PICO8)
+	setProp(im, palettes.PICO8_PINK, 40)
+
+	in := make(Interests, len(palettes.PICO8))
+	in[14] = 0.8
+	got := in.Drift(im, 0.5)
+
+	if math.Abs(got[14]-0.6) > 0.001 {
+		t.Errorf("Drift(im[40%% pink], 0.5)[pink] => %f, but expected 0.6", got[14])
+	}
+	if math.Abs(got[0]-0.3) > 0.001 {
+		t.Errorf("Drift(im[40%% pink], 0.5)[black] => %f, but expected 0.3", got[0])
+	}
+	if in[14] != 0.8 {
+		t.Errorf("Drift modified the original interests: %f", in[14])
+	}
+}
+
+func TestToPalette(t *testing.T) {
+	im := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	im.Set(0, 0, color.NRGBA{255, 0, 77, 255})
+	im.Set(1, 0, color.NRGBA{250, 5, 80, 255})
+	im.Set(0, 1, color.NRGBA{0, 0, 0, 255})
+	im.Set(1, 1, color.NRGBA{10, 10, 10, 255})
+
+	got, off := ToPalette(im, palettes.PICO8)
+
+	if off != 2 {
+		t.Errorf("ToPalette(im) off-palette => %d, expected 2", off)
+	}
+	cnts := CountColors(got)
+	if cnts[palettes.PICO8_RED] != 2 || cnts[palettes.PICO8_BLACK] != 2 {
+		t.Errorf("CountColors(ToPalette(im)) => %v, expected 2 red and 2 black", cnts)
+	}
+}
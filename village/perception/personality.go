@@ -0,0 +1,146 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+// ColorsWeight is the name of the Personality weight for its color interests.
+const ColorsWeight = "colors"
+
+// PersonalityCompositions are the Compositions NewPersonality gives a random
+// weight, in the order it generates them.
+//
+// The list is fixed, so that adding a composition doesn't change the
+// personality a seed generates. Other compositions have no weight unless it
+// is set in Weights.
+var PersonalityCompositions = []string{"tl-corners"}
+
+// Tools are the strategies an artist can prefer.
+var Tools = []string{"ideal", "plurality", "random"}
+
+// Struct Personality is the taste of an artist.
+type Personality struct {
+	Seed int64 `json:"seed"`
+	// Interests are the ideal amount of each color.
+	Interests Interests `json:"interests"`
+	// Weights are how much the color interests (ColorsWeight) and each of the
+	// Compositions contribute to the overall rating.
+	Weights map[string]float64 `json:"weights"`
+	// Tools are the strategies the artist prefers, most preferred first.
+	Tools []string `json:"tools"`
+}
+
+// DefaultPersonality is an artist with the predefined "interests".
+//
+// It rates images the same as RateWholeImage.
+func DefaultPersonality() *Personality {
+	return &Personality{
+		Interests: DefaultInterests,
+		Weights:   map[string]float64{ColorsWeight: 1.0},
+		Tools:     []string{"ideal"},
+	}
+}
+
+// NewPersonality randomly generates a personality from a seed.
+//
+// The same seed always generates the same personality.
+func NewPersonality(seed int64) *Personality {
+	rng := rand.New(rand.NewSource(seed))
+	p := &Personality{
+		Seed:      seed,
+		Interests: make(Interests, len(palettes.PICO8)),
+		Weights:   map[string]float64{ColorsWeight: rng.Float64()},
+	}
+	for i := range p.Interests {
+		p.Interests[i] = rng.Float64()
+	}
+	// Generate weights in a fixed order so that the seed is reproducible.
+	for _, n := range PersonalityCompositions {
+		p.Weights[n] = rng.Float64()
+	}
+	for _, i := range rng.Perm(len(Tools)) {
+		p.Tools = append(p.Tools, Tools[i])
+	}
+	return p
+}
+
+// LoadPersonality reads a personality from a JSON file.
+func LoadPersonality(path string) (*Personality, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", path, err)
+	}
+	p := &Personality{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("Error decoding %s: %s", path, err)
+	}
+	if len(p.Interests) != len(palettes.PICO8) {
+		return nil, fmt.Errorf("Error in %s: got %d interests, expected %d", path, len(p.Interests), len(palettes.PICO8))
+	}
+	for n := range p.Weights {
+		if _, ok := Compositions[n]; !ok && n != ColorsWeight {
+			return nil, fmt.Errorf("Error in %s: unknown rating %q", path, n)
+		}
+	}
+	return p, nil
+}
+
+// Save writes a personality to a JSON file.
+func (p *Personality) Save(path string) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding %s: %s", path, err)
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("Error writing %s: %s", path, err)
+	}
+	return nil
+}
+
+// Tool returns the most preferred strategy.
+func (p *Personality) Tool() string {
+	if len(p.Tools) == 0 {
+		return "ideal"
+	}
+	return p.Tools[0]
+}
+
+// Rate rates an image as the weighted average of the personality's ratings.
+func (p *Personality) Rate(im image.Image) float64 {
+	// Sum in a fixed order, since floating point addition isn't associative
+	// and the same image must always get the same rating.
+	var names []string
+	for n := range p.Weights {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	rt := 0.0
+	total := 0.0
+	for _, n := range names {
+		w := p.Weights[n]
+		if w == 0 {
+			continue
+		}
+		if n == ColorsWeight {
+			rt += w * p.Interests.Rate(im)
+		} else {
+			rt += w * Compositions[n](im)
+		}
+		total += w
+	}
+	if total == 0 {
+		return 0
+	}
+	return rt / total
+}
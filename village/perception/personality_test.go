@@ -0,0 +1,118 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"image"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+func TestNewPersonalityIsReproducible(t *testing.T) {
+	a := NewPersonality(42)
+	b := NewPersonality(42)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("NewPersonality(42) => %#v, then %#v", a, b)
+	}
+	c := NewPersonality(43)
+	if reflect.DeepEqual(a.Interests, c.Interests) {
+		t.Errorf("Expected different seeds to have different interests, got %v", a.Interests)
+	}
+	if len(a.Interests) != len(palettes.PICO8) {
+		t.Errorf("len(Interests) => %d, expected %d", len(a.Interests), len(palettes.PICO8))
+	}
+	if len(a.Tools) != len(Tools) {
+		t.Errorf("Tools => %v, expected a permutation of %v", a.Tools, Tools)
+	}
+}
+
+// TestNewPersonalityIsStable checks that a seed generates the same
+// personality as when personalities were added, however many Compositions
+// there are now.
+func TestNewPersonalityIsStable(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	want := &Personality{
+		Seed:      42,
+		Interests: make(Interests, len(palettes.PICO8)),
+		Weights:   map[string]float64{ColorsWeight: rng.Float64()},
+	}
+	for i := range want.Interests {
+		want.Interests[i] = rng.Float64()
+	}
+	want.Weights["tl-corners"] = rng.Float64()
+	for _, i := range rng.Perm(len(Tools)) {
+		want.Tools = append(want.Tools, Tools[i])
+	}
+	if got := NewPersonality(42); !reflect.DeepEqual(got, want) {
+		t.Errorf("NewPersonality(42) => %#v, expected %#v", got, want)
+	}
+}
+
+func TestDefaultPersonalityRate(t *testing.T) {
+	r := image.Rectangle{image.Point{0, 0}, image.Point{10, 10}}
+	im := image.NewPaletted(r, palettes.PICO8)
+	setProp(im, palettes.PICO8_PINK, 31)
+
+	got := DefaultPersonality().Rate(im)
+	want := RateWholeImage(im)
+	if math.Abs(got-want) > 0.000001 {
+		t.Errorf("DefaultPersonality().Rate(im) => %f, expected %f", got, want)
+	}
+}
+
+// TestPersonalityRateIsReproducible checks that the rating doesn't depend on
+// the order of the Weights map.
+func TestPersonalityRateIsReproducible(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	im := image.NewPaletted(image.Rect(0, 0, 16, 16), palettes.PICO8)
+	for i := range im.Pix {
+		im.Pix[i] = uint8(rng.Intn(len(palettes.PICO8)))
+	}
+	p := NewPersonality(42)
+	for n := range Compositions {
+		p.Weights[n] = rng.Float64()
+	}
+	want := p.Rate(im)
+	for i := 0; i < 20; i++ {
+		if got := p.Rate(im); got != want {
+			t.Fatalf("Rate(im) => %v, then %v", want, got)
+		}
+	}
+}
+
+func TestPersonalitySaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "perception")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "profile.json")
+
+	p := NewPersonality(7)
+	if err := p.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadPersonality(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("LoadPersonality(Save(p)) => %#v, expected %#v", got, p)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"interests": [0.5]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPersonality(path); err == nil {
+		t.Error("Expected error loading a profile with too few interests.")
+	}
+}
@@ -0,0 +1,536 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// These ratings judge pixel-art craft. Each is in [0, 1], and higher is
+// better, so penalties are 1 minus the amount of the problem.
+
+// NoiseTolerance is the fraction of neighboring pixels which can differ
+// before RateNoise penalizes an image.
+const NoiseTolerance = 0.25
+
+func sameColor(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+// luma returns the perceived brightness of a color, from 0 to 1.
+func luma(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 65535
+}
+
+// perceiveOrphan checks if the point at x, y differs from all of its
+// neighbors to the left, right, top and bottom.
+func perceiveOrphan(x, y int, im image.Image) float64 {
+	b := im.Bounds()
+	c := im.At(x, y)
+	neighbors := 0
+	for _, d := range []image.Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		pt := image.Point{X: x + d.X, Y: y + d.Y}
+		if !pt.In(b) {
+			continue
+		}
+		neighbors++
+		if sameColor(im.At(pt.X, pt.Y), c) {
+			return 0.0
+		}
+	}
+	if neighbors == 0 {
+		return 0.0
+	}
+	return 1.0
+}
+
+// RateOrphans penalizes pixels which don't touch any pixel of their color.
+func RateOrphans(im image.Image) float64 {
+	b := im.Bounds()
+	pxls := b.Dx() * b.Dy()
+	if pxls == 0 {
+		return 1.0
+	}
+	orphans := 0.0
+	for x := b.Min.X; x < b.Max.X; x++ {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			orphans += perceiveOrphan(x, y, im)
+		}
+	}
+	return 1.0 - orphans/float64(pxls)
+}
+
+// perceiveNoise counts how many of the pixels to the right and bottom of x, y
+// are a different color, and how many there are.
+func perceiveNoise(x, y int, im image.Image) (changes, pairs float64) {
+	b := im.Bounds()
+	c := im.At(x, y)
+	if x < b.Max.X-1 {
+		pairs++
+		if !sameColor(im.At(x+1, y), c) {
+			changes++
+		}
+	}
+	if y < b.Max.Y-1 {
+		pairs++
+		if !sameColor(im.At(x, y+1), c) {
+			changes++
+		}
+	}
+	return changes, pairs
+}
+
+// RateNoise penalizes images where many neighboring pixels differ.
+//
+// Up to NoiseTolerance of neighbors can differ, for detail, then the rating
+// falls to 0 when every neighbor differs.
+func RateNoise(im image.Image) float64 {
+	b := im.Bounds()
+	changes, pairs := 0.0, 0.0
+	for x := b.Min.X; x < b.Max.X; x++ {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			c, p := perceiveNoise(x, y, im)
+			changes += c
+			pairs += p
+		}
+	}
+	if pairs == 0 {
+		return 1.0
+	}
+	f := changes / pairs
+	if f <= NoiseTolerance {
+		return 1.0
+	}
+	return 1.0 - (f-NoiseTolerance)/(1.0-NoiseTolerance)
+}
+
+// Struct grid is a view of an image which can be transposed, so that the
+// same scan finds horizontal and vertical features.
+type grid struct {
+	im        image.Image
+	transpose bool
+}
+
+func (g grid) size() (w, h int) {
+	b := g.im.Bounds()
+	if g.transpose {
+		return b.Dy(), b.Dx()
+	}
+	return b.Dx(), b.Dy()
+}
+
+func (g grid) at(x, y int) color.Color {
+	b := g.im.Bounds()
+	if g.transpose {
+		return g.im.At(b.Min.X+y, b.Min.Y+x)
+	}
+	return g.im.At(b.Min.X+x, b.Min.Y+y)
+}
+
+// Struct run is a horizontal line of one color.
+type run struct {
+	start, length int
+	c             color.Color
+}
+
+func (r run) end() int {
+	return r.start + r.length
+}
+
+// rows splits each row of a grid into runs.
+func (g grid) rows() [][]run {
+	w, h := g.size()
+	rows := make([][]run, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := g.at(x, y)
+			rs := rows[y]
+			if len(rs) > 0 && sameColor(rs[len(rs)-1].c, c) {
+				rs[len(rs)-1].length++
+			} else {
+				rs = append(rs, run{start: x, length: 1, c: c})
+			}
+			rows[y] = rs
+		}
+	}
+	return rows
+}
+
+// steps follows staircase lines down from each run, where the next run of
+// the same color starts just past its end (dir 1) or ends just before its
+// start (dir -1). It returns the run lengths of every staircase.
+func steps(rows [][]run, dir int) [][]int {
+	// Index the runs of each row by where they start and end, so that
+	// finding the next run doesn't scan the row. Runs don't overlap, so at
+	// most one starts or ends at each x.
+	w := 0
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		w = rows[0][len(rows[0])-1].end()
+	}
+	at := make([]int, len(rows)*(w+1))
+	for i := range at {
+		at[i] = -1
+	}
+	for y, rs := range rows {
+		for i, r := range rs {
+			if dir > 0 {
+				at[y*(w+1)+r.start] = i
+			} else {
+				at[y*(w+1)+r.end()] = i
+			}
+		}
+	}
+	next := func(y int, r run) (int, bool) {
+		if y+1 >= len(rows) {
+			return 0, false
+		}
+		x := r.start
+		if dir > 0 {
+			x = r.end()
+		}
+		i := at[(y+1)*(w+1)+x]
+		if i < 0 || !sameColor(rows[y+1][i].c, r.c) {
+			return 0, false
+		}
+		return i, true
+	}
+	// Find which runs continue a staircase, so chains start at the top.
+	continued := make([][]bool, len(rows))
+	for y, rs := range rows {
+		continued[y] = make([]bool, len(rs))
+	}
+	for y, rs := range rows {
+		for _, r := range rs {
+			if i, ok := next(y, r); ok {
+				continued[y+1][i] = true
+			}
+		}
+	}
+	var chains [][]int
+	for y, rs := range rows {
+		for i, r := range rs {
+			if continued[y][i] {
+				continue
+			}
+			chain := []int{r.length}
+			for cy, cr := y, r; ; {
+				ni, ok := next(cy, cr)
+				if !ok {
+					break
+				}
+				cy, cr = cy+1, rows[cy+1][ni]
+				chain = append(chain, cr.length)
+			}
+			if len(chain) >= 3 {
+				chains = append(chains, chain)
+			}
+		}
+	}
+	return chains
+}
+
+// RateJaggies penalizes staircase lines with irregular steps.
+//
+// Clean lines have steps of the same length, or lengths which change by at
+// most one pixel from step to step, such as 1, 1, 2, 2, 3.
+func RateJaggies(im image.Image) float64 {
+	jaggies, pairs := 0, 0
+	for _, g := range []grid{{im, false}, {im, true}} {
+		rows := g.rows()
+		for _, dir := range []int{1, -1} {
+			for _, chain := range steps(rows, dir) {
+				for i := 1; i < len(chain); i++ {
+					pairs++
+					d := chain[i] - chain[i-1]
+					if d > 1 || d < -1 {
+						jaggies++
+					}
+				}
+			}
+		}
+	}
+	if pairs == 0 {
+		return 1.0
+	}
+	return 1.0 - float64(jaggies)/float64(pairs)
+}
+
+// between returns true if b is strictly between a and c in brightness.
+func between(a, b, c color.Color) bool {
+	la, lb, lc := luma(a), luma(b), luma(c)
+	return (la < lb && lb < lc) || (lc < lb && lb < la)
+}
+
+// RateBanding penalizes thin strips of an in-between shade which run
+// parallel to the edge they soften.
+//
+// A strip is a run of up to 2 pixels between two other colors. It bands if
+// the next row has the same strip, at most one pixel over.
+func RateBanding(im image.Image) float64 {
+	bands, strips := 0, 0
+	for _, g := range []grid{{im, false}, {im, true}} {
+		rows := g.rows()
+		for y, rs := range rows {
+			for i := 1; i < len(rs)-1; i++ {
+				a, s, c := rs[i-1], rs[i], rs[i+1]
+				if s.length > 2 || !between(a.c, s.c, c.c) {
+					continue
+				}
+				strips++
+				if y+1 >= len(rows) {
+					continue
+				}
+				nrs := rows[y+1]
+				for j := 1; j < len(nrs)-1; j++ {
+					na, ns, nc := nrs[j-1], nrs[j], nrs[j+1]
+					d := ns.start - s.start
+					if ns.length == s.length && d >= -1 && d <= 1 &&
+						sameColor(ns.c, s.c) && sameColor(na.c, a.c) && sameColor(nc.c, c.c) {
+						bands++
+						break
+					}
+				}
+			}
+		}
+	}
+	if strips == 0 {
+		return 1.0
+	}
+	return 1.0 - float64(bands)/float64(strips)
+}
+
+// Struct shape separates an image into its background, the most common
+// color, and the foreground.
+type shape struct {
+	im     image.Image
+	bg     color.Color
+	w, h   int
+	fg     []bool
+	border []bool
+}
+
+func newShape(im image.Image) *shape {
+	b := im.Bounds()
+	s := &shape{im: im, w: b.Dx(), h: b.Dy()}
+	// Break ties by brightness, so that the background doesn't depend on
+	// the order of the colors.
+	max := -1
+	hist := cachedHistogram(im)
+	for i, c := range hist.Colors {
+		n := hist.Counts[i]
+		if n == 0 {
+			continue
+		}
+		if s.bg == nil || n > max || (n == max && luma(c) < luma(s.bg)) {
+			s.bg, max = c, n
+		}
+	}
+	s.fg = make([]bool, s.w*s.h)
+	s.border = make([]bool, s.w*s.h)
+	for y := 0; y < s.h; y++ {
+		for x := 0; x < s.w; x++ {
+			s.fg[y*s.w+x] = !sameColor(s.at(x, y), s.bg)
+		}
+	}
+	for y := 0; y < s.h; y++ {
+		for x := 0; x < s.w; x++ {
+			if !s.fg[y*s.w+x] {
+				continue
+			}
+			for _, d := range []image.Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				if !s.isFg(x+d.X, y+d.Y) {
+					s.border[y*s.w+x] = true
+				}
+			}
+		}
+	}
+	return s
+}
+
+func (s *shape) at(x, y int) color.Color {
+	b := s.im.Bounds()
+	return s.im.At(b.Min.X+x, b.Min.Y+y)
+}
+
+// isFg returns true if x, y is in the foreground. Outside the image is
+// background.
+func (s *shape) isFg(x, y int) bool {
+	return x >= 0 && x < s.w && y >= 0 && y < s.h && s.fg[y*s.w+x]
+}
+
+func (s *shape) isInterior(x, y int) bool {
+	return s.isFg(x, y) && !s.border[y*s.w+x]
+}
+
+// RateOutlines rewards foreground shapes with a clean outline: a single
+// pixel wide line of one color, darker than what it surrounds.
+func RateOutlines(im image.Image) float64 {
+	s := newShape(im)
+	// The outline color is the most common color on the border.
+	cnts := make(map[color.Color]int)
+	border := 0
+	for y := 0; y < s.h; y++ {
+		for x := 0; x < s.w; x++ {
+			if s.border[y*s.w+x] {
+				cnts[s.at(x, y)]++
+				border++
+			}
+		}
+	}
+	if border == 0 {
+		return 0.0
+	}
+	var outline color.Color
+	max := -1
+	for c, n := range cnts {
+		if outline == nil || n > max || (n == max && luma(c) < luma(outline)) {
+			outline, max = c, n
+		}
+	}
+	// Penalize outline pixels which have more outline inside them, which
+	// makes the line thick.
+	clean, thick, inside, darker := 0, 0, 0, 0
+	for y := 0; y < s.h; y++ {
+		for x := 0; x < s.w; x++ {
+			if !s.border[y*s.w+x] || !sameColor(s.at(x, y), outline) {
+				continue
+			}
+			clean++
+			for _, d := range []image.Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				nx, ny := x+d.X, y+d.Y
+				if !s.isInterior(nx, ny) {
+					continue
+				}
+				inside++
+				if sameColor(s.at(nx, ny), outline) {
+					thick++
+					break
+				}
+				if luma(s.at(nx, ny)) > luma(outline) {
+					darker++
+				}
+			}
+		}
+	}
+	v := float64(clean-thick) / float64(border)
+	if inside > 0 {
+		v *= float64(darker) / float64(inside)
+	}
+	return v
+}
+
+// lightConsistency measures how much the shading inside shapes agrees on a
+// direction, from 0 for no agreement to 1 when all brightness changes point
+// the same way.
+func lightConsistency(s *shape) float64 {
+	sx, sy, total := 0.0, 0.0, 0.0
+	for y := 0; y < s.h; y++ {
+		for x := 0; x < s.w; x++ {
+			if !s.isInterior(x, y) {
+				continue
+			}
+			l := luma(s.at(x, y))
+			if s.isInterior(x+1, y) {
+				d := luma(s.at(x+1, y)) - l
+				sx += d
+				total += math.Abs(d)
+			}
+			if s.isInterior(x, y+1) {
+				d := luma(s.at(x, y+1)) - l
+				sy += d
+				total += math.Abs(d)
+			}
+		}
+	}
+	if total == 0 {
+		return 0.0
+	}
+	return math.Hypot(sx, sy) / total
+}
+
+// RateLightDirection rewards shading which is lit from one direction.
+//
+// Outlines are left out, since they darken every side of a shape.
+func RateLightDirection(im image.Image) float64 {
+	return lightConsistency(newShape(im))
+}
+
+// distances returns how many steps each foreground pixel is from the
+// background.
+func (s *shape) distances() []int {
+	dist := make([]int, s.w*s.h)
+	var queue []image.Point
+	for y := 0; y < s.h; y++ {
+		for x := 0; x < s.w; x++ {
+			if s.border[y*s.w+x] {
+				dist[y*s.w+x] = 1
+				queue = append(queue, image.Point{x, y})
+			}
+		}
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, d := range []image.Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+			nx, ny := p.X+d.X, p.Y+d.Y
+			if !s.isFg(nx, ny) || dist[ny*s.w+nx] != 0 {
+				continue
+			}
+			dist[ny*s.w+nx] = dist[p.Y*s.w+p.X] + 1
+			queue = append(queue, image.Point{nx, ny})
+		}
+	}
+	return dist
+}
+
+// RatePillowShading penalizes shading which gets brighter toward the middle
+// of a shape from every side, instead of from a light source.
+func RatePillowShading(im image.Image) float64 {
+	s := newShape(im)
+	dist := s.distances()
+	var ds, ls []float64
+	for y := 0; y < s.h; y++ {
+		for x := 0; x < s.w; x++ {
+			if s.isFg(x, y) {
+				ds = append(ds, float64(dist[y*s.w+x]))
+				ls = append(ls, luma(s.at(x, y)))
+			}
+		}
+	}
+	pillow := math.Max(0, correlation(ds, ls)) * (1.0 - lightConsistency(s))
+	return 1.0 - pillow
+}
+
+// correlation returns the Pearson correlation of xs and ys, or 0 if either
+// doesn't vary.
+func correlation(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	if n < 2 {
+		return 0
+	}
+	mx, my := 0.0, 0.0
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx /= n
+	my /= n
+	sxy, sxx, syy := 0.0, 0.0, 0.0
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
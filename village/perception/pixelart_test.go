@@ -0,0 +1,143 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package perception
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+var pixelColors = map[byte]color.Color{
+	'.': palettes.PICO8_BLACK,
+	'b': palettes.PICO8_DARK_BLUE,
+	'd': palettes.PICO8_DARK_GRAY,
+	'l': palettes.PICO8_LIGHT_GRAY,
+	'w': palettes.PICO8_WHITE,
+	'p': palettes.PICO8_PINK,
+}
+
+// parseImage draws an image from rows of characters in pixelColors.
+func parseImage(rows ...string) *image.Paletted {
+	im := image.NewPaletted(image.Rect(0, 0, len(rows[0]), len(rows)), palettes.PICO8)
+	for y, row := range rows {
+		for x := 0; x < len(row); x++ {
+			im.Set(x, y, pixelColors[row[x]])
+		}
+	}
+	return im
+}
+
+var pixelarttests = []struct {
+	name   string
+	rating Rating
+	im     *image.Paletted
+	lo, hi float64
+}{
+	{"orphans none", RateOrphans, parseImage("...", "...", "..."), 1, 1},
+	{"orphans one", RateOrphans, parseImage("...", ".p.", "..."), 8.0 / 9, 8.0 / 9},
+	{"noise flat", RateNoise, parseImage("....", "....", "....", "...."), 1, 1},
+	{"noise checkerboard", RateNoise, parseImage(".w.w", "w.w.", ".w.w", "w.w."), 0, 0},
+	{"jaggies even steps", RateJaggies, parseImage(
+		"ww........",
+		"..ww......",
+		"....ww....",
+		"......ww..",
+		"........ww",
+	), 1, 1},
+	{"jaggies uneven steps", RateJaggies, parseImage(
+		"w.........",
+		".wwww.....",
+		".....w....",
+		"......wwww",
+		"..........",
+	), 0, 0.9},
+	{"banding parallel strip", RateBanding, parseImage(
+		"..dww",
+		"..dww",
+		"..dww",
+	), 0, 0.5},
+	{"banding single strip", RateBanding, parseImage(
+		".....",
+		"..dww",
+		".....",
+	), 1, 1},
+	{"outlines clean", RateOutlines, parseImage(
+		"........",
+		"..bbbb..",
+		"..bwwb..",
+		"..bwwb..",
+		"..bbbb..",
+		"........",
+	), 1, 1},
+	{"outlines none", RateOutlines, parseImage("....", "....", "...."), 0, 0},
+	{"outlines thick", RateOutlines, parseImage(
+		"..........",
+		"..bbbbbb..",
+		"..bbbbbb..",
+		"..bbwwbb..",
+		"..bbwwbb..",
+		"..bbbbbb..",
+		"..bbbbbb..",
+		"..........",
+	), 0, 0.5},
+	{"light from the left", RateLightDirection, parseImage(
+		"..........",
+		".bbbbbbbb.",
+		".bwwllddb.",
+		".bwwllddb.",
+		".bbbbbbbb.",
+		"..........",
+	), 0.99, 1},
+	{"pillow shading", RatePillowShading, parseImage(
+		"..........",
+		".bbbbbbbb.",
+		".bddddddb.",
+		".bdlllldb.",
+		".bdlwwldb.",
+		".bdlllldb.",
+		".bddddddb.",
+		".bbbbbbbb.",
+		"..........",
+	), 0, 0.5},
+	{"no pillow when lit from the left", RatePillowShading, parseImage(
+		"..........",
+		".bbbbbbbb.",
+		".bwwllddb.",
+		".bwwllddb.",
+		".bbbbbbbb.",
+		"..........",
+	), 0.99, 1},
+}
+
+func TestPixelArtRatings(t *testing.T) {
+	for _, tt := range pixelarttests {
+		got := tt.rating(tt.im)
+		if got < tt.lo-1e-9 || got > tt.hi+1e-9 {
+			t.Errorf("%s: rating => %f, expected in [%f, %f]", tt.name, got, tt.lo, tt.hi)
+		}
+	}
+}
+
+func TestPixelArtRatingsEmpty(t *testing.T) {
+	im := image.NewPaletted(image.Rect(0, 0, 0, 0), palettes.PICO8)
+	for n, r := range Compositions {
+		got := r(im)
+		if got < 0 || got > 1 || math.IsNaN(got) {
+			t.Errorf("%s(empty) => %f, expected in [0, 1]", n, got)
+		}
+	}
+}
+
+func BenchmarkRateJaggies(b *testing.B) {
+	im := newNoise(128, 128)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		RateJaggies(im)
+	}
+}
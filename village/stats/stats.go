@@ -0,0 +1,138 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package stats summarizes repeated measurements, such as benchmark runs.
+//
+// The intervals and tests resample with a given random source instead of
+// assuming a distribution, so they are deterministic for a fixed seed.
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// DefaultResamples is the number of resamples used when fewer than 1 are
+// asked for.
+const DefaultResamples = 10000
+
+// Mean returns the average of xs, or 0 if there are none.
+func Mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// StdDev returns the sample standard deviation of xs.
+func StdDev(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	m := Mean(xs)
+	ss := 0.0
+	for _, x := range xs {
+		ss += (x - m) * (x - m)
+	}
+	return math.Sqrt(ss / float64(len(xs)-1))
+}
+
+// BootstrapCI returns a confidence interval for the mean of xs, such as a
+// 95% interval for level 0.95, with the percentile bootstrap.
+//
+// If resamples is less than 1, DefaultResamples are made.
+func BootstrapCI(xs []float64, level float64, resamples int, rng *rand.Rand) (lo, hi float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	if resamples < 1 {
+		resamples = DefaultResamples
+	}
+	means := make([]float64, resamples)
+	for i := range means {
+		sum := 0.0
+		for range xs {
+			sum += xs[rng.Intn(len(xs))]
+		}
+		means[i] = sum / float64(len(xs))
+	}
+	sort.Float64s(means)
+	alpha := (1 - level) / 2
+	return quantile(means, alpha), quantile(means, 1-alpha)
+}
+
+// quantile returns the q quantile of sorted values.
+func quantile(sorted []float64, q float64) float64 {
+	i := int(q * float64(len(sorted)-1))
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// exactLimit is the largest number of pairs for which every sign flip is
+// tried.
+const exactLimit = 16
+
+// PairedPermutationTest returns the two-sided p-value that paired samples a
+// and b have the same mean.
+//
+// Under the null hypothesis, the sign of each difference a[i] - b[i] is
+// arbitrary. With up to 16 pairs every combination of signs is tried,
+// otherwise the signs are flipped at random the given number of times, or
+// DefaultResamples times if that is less than 1.
+func PairedPermutationTest(a, b []float64, resamples int, rng *rand.Rand) float64 {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	if n == 0 {
+		return 1
+	}
+	diffs := make([]float64, n)
+	for i := range diffs {
+		diffs[i] = a[i] - b[i]
+	}
+	observed := math.Abs(Mean(diffs))
+	// Allow for rounding when sums are equal.
+	const eps = 1e-12
+
+	extreme, total := 0, 0
+	count := func(signs func(i int) bool) {
+		sum := 0.0
+		for i, d := range diffs {
+			if signs(i) {
+				sum -= d
+			} else {
+				sum += d
+			}
+		}
+		if math.Abs(sum/float64(n)) >= observed-eps {
+			extreme++
+		}
+		total++
+	}
+	if n <= exactLimit {
+		for mask := 0; mask < 1<<uint(n); mask++ {
+			count(func(i int) bool { return mask&(1<<uint(i)) != 0 })
+		}
+		return float64(extreme) / float64(total)
+	}
+	if resamples < 1 {
+		resamples = DefaultResamples
+	}
+	for r := 0; r < resamples; r++ {
+		count(func(_ int) bool { return rng.Intn(2) == 1 })
+	}
+	// Count the observed signs, so the p-value is never 0.
+	return float64(extreme+1) / float64(total+1)
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMeanStdDev(t *testing.T) {
+	xs := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+	if got := Mean(xs); got != 5 {
+		t.Errorf("Mean(%v) => %f, expected 5", xs, got)
+	}
+	if got, want := StdDev(xs), math.Sqrt(32.0/7.0); math.Abs(got-want) > 1e-9 {
+		t.Errorf("StdDev(%v) => %f, expected %f", xs, got, want)
+	}
+	if got := Mean(nil); got != 0 {
+		t.Errorf("Mean(nil) => %f, expected 0", got)
+	}
+}
+
+func TestBootstrapCI(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	xs := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	lo, hi := BootstrapCI(xs, 0.95, 2000, rng)
+	if !(lo < Mean(xs) && Mean(xs) < hi) {
+		t.Errorf("BootstrapCI(%v) => [%f, %f], expected to contain the mean", xs, lo, hi)
+	}
+	if lo < 1 || hi > 10 {
+		t.Errorf("BootstrapCI(%v) => [%f, %f], expected within the data", xs, lo, hi)
+	}
+
+	lo2, hi2 := BootstrapCI(xs, 0.95, 2000, rand.New(rand.NewSource(1)))
+	if lo != lo2 || hi != hi2 {
+		t.Error("Expected BootstrapCI to be deterministic for a seed.")
+	}
+
+	// Too few resamples use the default, rather than panicking.
+	lo0, hi0 := BootstrapCI(xs, 0.95, 0, rand.New(rand.NewSource(1)))
+	wantLo, wantHi := BootstrapCI(xs, 0.95, DefaultResamples, rand.New(rand.NewSource(1)))
+	if lo0 != wantLo || hi0 != wantHi {
+		t.Errorf("BootstrapCI(%v, resamples 0) => [%f, %f], expected [%f, %f]", xs, lo0, hi0, wantLo, wantHi)
+	}
+}
+
+var permutationtests = []struct {
+	name string
+	a, b []float64
+	lo   float64
+	hi   float64
+}{
+	{"same", []float64{1, 2, 3, 4}, []float64{1, 2, 3, 4}, 1, 1},
+	// Only all-positive and all-negative differences are as extreme: 2/256.
+	{"always better", []float64{2, 3, 4, 5, 6, 7, 8, 9}, []float64{1, 2, 3, 4, 5, 6, 7, 8}, 2.0 / 256, 2.0 / 256},
+	{"sampled", make20(1), make20(0), 0, 0.001},
+}
+
+func make20(offset float64) []float64 {
+	xs := make([]float64, 20)
+	for i := range xs {
+		xs[i] = float64(i) + offset
+	}
+	return xs
+}
+
+func TestPairedPermutationTest(t *testing.T) {
+	for _, tt := range permutationtests {
+		rng := rand.New(rand.NewSource(1))
+		got := PairedPermutationTest(tt.a, tt.b, 10000, rng)
+		if got < tt.lo-1e-9 || got > tt.hi+1e-9 {
+			t.Errorf("%s: PairedPermutationTest(...) => %f, expected in [%f, %f]", tt.name, got, tt.lo, tt.hi)
+		}
+	}
+}
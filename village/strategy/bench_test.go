@@ -0,0 +1,123 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package strategy
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/gui"
+	"github.com/tswast/pixelsketches/village/perception"
+)
+
+// newBenchAppState is a canvas part way through a drawing, with the cursor
+// in the middle of it.
+func newBenchAppState() *gui.AppState {
+	app := gui.NewAppState()
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		x, y := rng.Intn(gui.ImageWidth), rng.Intn(gui.ImageHeight)
+		app.Image.Set(x, y, palettes.PICO8[rng.Intn(len(palettes.PICO8))])
+	}
+	app.Color = palettes.PICO8_PINK
+	app.Cursor.Pos.X = gui.ImageX + gui.ImageWidth/2
+	app.Cursor.Pos.Y = gui.ImageHeight / 2
+	return app
+}
+
+func benchmarkIdeal(b *testing.B, rating perception.Rating) {
+	app := newBenchAppState()
+	s := &Ideal{Rating: rating, Rand: rand.New(rand.NewSource(1))}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Strategize(app)
+	}
+}
+
+// maxIdealAllocs is the most allocations a step of Ideal may make. Before
+// simulating on pooled scratch canvases, a step made thousands.
+const maxIdealAllocs = 100
+
+func TestIdealAllocs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping allocation count in short mode")
+	}
+	app := newBenchAppState()
+	s := &Ideal{Rating: perception.RateBlack, Rand: rand.New(rand.NewSource(1))}
+	// Warm up the pools and the color grid.
+	s.Strategize(app)
+	if n := testing.AllocsPerRun(5, func() { s.Strategize(app) }); n > maxIdealAllocs {
+		t.Errorf("Ideal.Strategize made %.0f allocations per step, want at most %d", n, maxIdealAllocs)
+	}
+}
+
+// TestIdealConcurrent checks that steps of the same Ideal can run at the
+// same time, with the race detector.
+func TestIdealConcurrent(t *testing.T) {
+	s := &Ideal{Rating: perception.RateBlack, Rand: rand.New(rand.NewSource(1)), Workers: 2}
+	done := make(chan bool)
+	for i := 0; i < 2; i++ {
+		go func() {
+			app := newBenchAppState()
+			for j := 0; j < 3; j++ {
+				act, _ := s.Strategize(app)
+				app.ApplyAction(&act)
+			}
+			done <- true
+		}()
+	}
+	<-done
+	<-done
+}
+
+// BenchmarkIdealWorkers runs each number of workers with as many CPUs, so
+// the time of a step should fall as workers are added, given enough cores.
+func BenchmarkIdealWorkers(b *testing.B) {
+	for _, workers := range []int{1, 2, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(workers))
+			app := newBenchAppState()
+			s := &Ideal{Rating: perception.RateBlack, Rand: rand.New(rand.NewSource(1)), Workers: workers}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.Strategize(app)
+			}
+		})
+	}
+}
+
+func BenchmarkIdealBlack(b *testing.B) {
+	benchmarkIdeal(b, perception.RateBlack)
+}
+
+func BenchmarkIdealWholeImage(b *testing.B) {
+	benchmarkIdeal(b, perception.RateWholeImage)
+}
+
+func BenchmarkIdealPersonalityColors(b *testing.B) {
+	pers := perception.NewPersonality(1)
+	pers.Weights = map[string]float64{perception.ColorsWeight: 1.0}
+	benchmarkIdeal(b, pers.Rate)
+}
+
+func BenchmarkPlurality(b *testing.B) {
+	app := newBenchAppState()
+	s := &Plurality{}
+	for i := 0; i < 4; i++ {
+		pers := perception.NewPersonality(int64(i))
+		pers.Weights = map[string]float64{perception.ColorsWeight: 1.0}
+		s.Voters = append(s.Voters, &Ideal{Rating: pers.Rate, Rand: rand.New(rand.NewSource(int64(i)))})
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Strategize(app)
+	}
+}
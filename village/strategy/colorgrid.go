@@ -0,0 +1,198 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package strategy
+
+import (
+	"bytes"
+	"image"
+)
+
+// colorGridCell is the width and height of the buckets of a colorGrid.
+const colorGridCell = 8
+
+// Struct colorGrid is a spatial index of where each color is on a paletted
+// canvas: a grid of buckets, each counting the pixels of every palette index
+// in it.
+//
+// It finds the nearest pixel of a color by searching buckets outward from a
+// point, skipping buckets without that color, rather than scanning the
+// canvas. Set keeps it up to date as the canvas is painted.
+type colorGrid struct {
+	rect image.Rectangle
+	// pix are the palette indexes of the indexed canvas, row by row.
+	pix    []uint8
+	colors int
+	// bw and bh are the number of buckets across and down.
+	bw, bh int
+	// counts are the number of pixels of each color in each bucket, at
+	// (by*bw+bx)*colors + c.
+	counts []int
+	// totals are the number of pixels of each color.
+	totals []int
+}
+
+func newColorGrid(im *image.Paletted) *colorGrid {
+	ix := &colorGrid{}
+	ix.reset(im)
+	return ix
+}
+
+// reset indexes a canvas from scratch.
+func (ix *colorGrid) reset(im *image.Paletted) {
+	ix.rect = im.Rect
+	w, h := im.Rect.Dx(), im.Rect.Dy()
+	ix.colors = len(im.Palette)
+	ix.bw = (w + colorGridCell - 1) / colorGridCell
+	ix.bh = (h + colorGridCell - 1) / colorGridCell
+	ix.pix = make([]uint8, w*h)
+	ix.counts = make([]int, ix.bw*ix.bh*ix.colors)
+	ix.totals = make([]int, ix.colors)
+	for y := 0; y < h; y++ {
+		i := im.PixOffset(im.Rect.Min.X, im.Rect.Min.Y+y)
+		copy(ix.pix[y*w:(y+1)*w], im.Pix[i:i+w])
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := ix.pix[y*w+x]
+			ix.counts[ix.bucket(x, y)*ix.colors+int(c)]++
+			ix.totals[c]++
+		}
+	}
+}
+
+// copyFrom makes ix a copy of src, reusing ix's slices if they are big
+// enough.
+func (ix *colorGrid) copyFrom(src *colorGrid) {
+	pix, counts, totals := ix.pix, ix.counts, ix.totals
+	*ix = *src
+	ix.pix = append(pix[:0], src.pix...)
+	ix.counts = append(counts[:0], src.counts...)
+	ix.totals = append(totals[:0], src.totals...)
+}
+
+// bucket returns the bucket of a point, relative to rect.Min.
+func (ix *colorGrid) bucket(x, y int) int {
+	return (y/colorGridCell)*ix.bw + x/colorGridCell
+}
+
+// Set records that the pixel at x, y is now palette index c.
+func (ix *colorGrid) Set(x, y int, c uint8) {
+	x -= ix.rect.Min.X
+	y -= ix.rect.Min.Y
+	i := y*ix.rect.Dx() + x
+	old := ix.pix[i]
+	if old == c {
+		return
+	}
+	b := ix.bucket(x, y) * ix.colors
+	ix.counts[b+int(old)]--
+	ix.totals[old]--
+	ix.counts[b+int(c)]++
+	ix.totals[c]++
+	ix.pix[i] = c
+}
+
+// update brings the index up to date with a canvas, calling Set for each
+// pixel which changed since it was indexed.
+func (ix *colorGrid) update(im *image.Paletted) {
+	if im.Rect != ix.rect || len(im.Palette) != ix.colors {
+		ix.reset(im)
+		return
+	}
+	w := ix.rect.Dx()
+	for y := 0; y < ix.rect.Dy(); y++ {
+		i := im.PixOffset(ix.rect.Min.X, ix.rect.Min.Y+y)
+		row, old := im.Pix[i:i+w], ix.pix[y*w:(y+1)*w]
+		if bytes.Equal(row, old) {
+			continue
+		}
+		for x, c := range row {
+			if c != old[x] {
+				ix.Set(ix.rect.Min.X+x, ix.rect.Min.Y+y, c)
+			}
+		}
+	}
+}
+
+// rectDistance is the number of actions from a point to the nearest point of
+// a rectangle.
+func rectDistance(q image.Point, r image.Rectangle) int {
+	return actionDistance(q, image.Point{
+		X: clamp(q.X, r.Min.X, r.Max.X-1),
+		Y: clamp(q.Y, r.Min.Y, r.Max.Y-1),
+	})
+}
+
+// nearest finds the pixel of palette index c in r which takes the fewest
+// actions to reach from q, in the coordinates of the canvas. Of equally close
+// pixels, it finds the one with the least X, then the least Y.
+func (ix *colorGrid) nearest(c uint8, q image.Point, r image.Rectangle) (pt image.Point, dist int, found bool) {
+	r = r.Intersect(ix.rect)
+	if r.Empty() || int(c) >= ix.colors || ix.totals[c] == 0 {
+		return pt, 0, false
+	}
+	// Search from the bucket nearest to q, one ring of buckets at a time.
+	lq := q.Sub(ix.rect.Min)
+	qbx := clamp(lq.X, 0, ix.rect.Dx()-1) / colorGridCell
+	qby := clamp(lq.Y, 0, ix.rect.Dy()-1) / colorGridCell
+	w := ix.rect.Dx()
+	search := func(bx, by int) {
+		if ix.counts[(by*ix.bw+bx)*ix.colors+int(c)] == 0 {
+			return
+		}
+		br := image.Rect(bx*colorGridCell, by*colorGridCell, (bx+1)*colorGridCell, (by+1)*colorGridCell)
+		br = br.Add(ix.rect.Min).Intersect(r)
+		if br.Empty() || (found && rectDistance(q, br) > dist) {
+			return
+		}
+		for x := br.Min.X; x < br.Max.X; x++ {
+			for y := br.Min.Y; y < br.Max.Y; y++ {
+				if ix.pix[(y-ix.rect.Min.Y)*w+x-ix.rect.Min.X] != c {
+					continue
+				}
+				p := image.Point{X: x, Y: y}
+				d := actionDistance(q, p)
+				if !found || d < dist || (d == dist && (x < pt.X || (x == pt.X && y < pt.Y))) {
+					pt, dist, found = p, d, true
+				}
+			}
+		}
+	}
+	for ring := 0; ; ring++ {
+		// Every pixel in this ring is at least this far from q.
+		if found && ring > 0 && (ring-1)*colorGridCell+1 > dist {
+			break
+		}
+		if qbx-ring < 0 && qby-ring < 0 && qbx+ring >= ix.bw && qby+ring >= ix.bh {
+			break
+		}
+		for by := qby - ring; by <= qby+ring; by++ {
+			if by < 0 || by >= ix.bh {
+				continue
+			}
+			step := 2 * ring
+			if by == qby-ring || by == qby+ring || step == 0 {
+				step = 1
+			}
+			for bx := qbx - ring; bx <= qbx+ring; bx += step {
+				if bx >= 0 && bx < ix.bw {
+					search(bx, by)
+				}
+			}
+		}
+	}
+	return pt, dist, found
+}
+
+// clamp limits v to between lo and hi.
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,135 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package strategy
+
+import (
+	"image"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+// newSparseImage is a black image with a few pixels of other colors.
+func newSparseImage(rng *rand.Rand, r image.Rectangle, n int) *image.Paletted {
+	im := image.NewPaletted(r, palettes.PICO8)
+	for i := 0; i < n; i++ {
+		x, y := r.Min.X+rng.Intn(r.Dx()), r.Min.Y+rng.Intn(r.Dy())
+		im.SetColorIndex(x, y, uint8(rng.Intn(len(palettes.PICO8))))
+	}
+	return im
+}
+
+// scanNearest is colorGrid.nearest, by scanning every pixel.
+func scanNearest(im *image.Paletted, c uint8, q image.Point, r image.Rectangle) (image.Point, int, bool) {
+	var pt image.Point
+	dist := 0
+	found := false
+	r = r.Intersect(im.Rect)
+	for x := r.Min.X; x < r.Max.X; x++ {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			if im.ColorIndexAt(x, y) != c {
+				continue
+			}
+			d := actionDistance(q, image.Point{X: x, Y: y})
+			if !found || d < dist {
+				pt, dist, found = image.Point{X: x, Y: y}, d, true
+			}
+		}
+	}
+	return pt, dist, found
+}
+
+func checkNearest(t *testing.T, rng *rand.Rand, g *colorGrid, im *image.Paletted) {
+	r := im.Rect
+	for i := 0; i < 500; i++ {
+		// Query from points a little way off the canvas, too.
+		q := image.Point{
+			X: r.Min.X - 10 + rng.Intn(r.Dx()+20),
+			Y: r.Min.Y - 10 + rng.Intn(r.Dy()+20),
+		}
+		x0, x1 := r.Min.X+rng.Intn(r.Dx()), r.Min.X+rng.Intn(r.Dx())
+		y0, y1 := r.Min.Y+rng.Intn(r.Dy()), r.Min.Y+rng.Intn(r.Dy())
+		box := image.Rect(x0, y0, x1+1, y1+1)
+		if i%4 == 0 {
+			box = r
+		}
+		c := uint8(rng.Intn(len(im.Palette)))
+		wantPt, wantDist, wantFound := scanNearest(im, c, q, box)
+		pt, dist, found := g.nearest(c, q, box)
+		if found != wantFound || (found && (pt != wantPt || dist != wantDist)) {
+			t.Fatalf("nearest(%d, %v, %v) => %v, %d, %v, want %v, %d, %v",
+				c, q, box, pt, dist, found, wantPt, wantDist, wantFound)
+		}
+	}
+}
+
+func TestColorGridNearest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, 89, 64),
+		image.Rect(3, 2, 40, 29),
+		image.Rect(0, 0, 1, 1),
+	} {
+		for _, n := range []int{0, 5, 200, 2000} {
+			im := newSparseImage(rng, r, n)
+			checkNearest(t, rng, newColorGrid(im), im)
+		}
+	}
+}
+
+func TestColorGridUpdate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	r := image.Rect(3, 2, 40, 29)
+	im := newSparseImage(rng, r, 100)
+	g := newColorGrid(im)
+	for step := 0; step < 20; step++ {
+		for i := 0; i < 1+rng.Intn(20); i++ {
+			x, y := r.Min.X+rng.Intn(r.Dx()), r.Min.Y+rng.Intn(r.Dy())
+			im.SetColorIndex(x, y, uint8(rng.Intn(len(im.Palette))))
+		}
+		g.update(im)
+		if want := newColorGrid(im); !reflect.DeepEqual(g, want) {
+			t.Fatalf("step %d: updated grid differs from a new grid of the canvas", step)
+		}
+		checkNearest(t, rng, g, im)
+
+		// Copies made for workers match, and don't share pixels.
+		var cp colorGrid
+		cp.copyFrom(g)
+		if !reflect.DeepEqual(&cp, g) {
+			t.Fatalf("step %d: copy of grid differs", step)
+		}
+		cp.Set(r.Min.X, r.Min.Y, uint8((int(cp.pix[0])+1)%cp.colors))
+		if reflect.DeepEqual(&cp, g) {
+			t.Fatalf("step %d: setting a pixel of the copy changed the grid", step)
+		}
+	}
+
+	// A canvas of another size is indexed again.
+	im = newSparseImage(rng, image.Rect(0, 0, 20, 10), 10)
+	g.update(im)
+	if want := newColorGrid(im); !reflect.DeepEqual(g, want) {
+		t.Errorf("grid of resized canvas differs from a new grid of it")
+	}
+}
+
+// BenchmarkColorGridNearest finds the nearest pixel of every color from the
+// middle of a canvas much larger than the GUI's.
+func BenchmarkColorGridNearest(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	r := image.Rect(0, 0, 512, 512)
+	im := newSparseImage(rng, r, 5000)
+	g := newColorGrid(im)
+	q := image.Point{X: 256, Y: 256}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for c := range im.Palette {
+			g.nearest(uint8(c), q, r)
+		}
+	}
+}
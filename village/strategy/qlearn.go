@@ -0,0 +1,235 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+
+	"github.com/tswast/pixelsketches/village/gui"
+	"github.com/tswast/pixelsketches/village/perception"
+)
+
+// qRewardScale scales rating changes, which are tiny for a single pixel, to a
+// reward that the weights can learn from.
+const qRewardScale = 1000.0
+
+// qActions are the actions a QLearner chooses from, in the order of its
+// weights.
+var qActions = func() []gui.Action {
+	var acts []gui.Action
+	for _, dir := range directions {
+		acts = append(acts, gui.Action{Horizontal: dir.h, Vertical: dir.v})
+		acts = append(acts, gui.Action{Horizontal: dir.h, Vertical: dir.v, Pressed: true})
+	}
+	return acts
+}()
+
+// neighbors are the offsets of the pixels around the cursor.
+var neighbors = []image.Point{
+	{-1, -1}, {0, -1}, {1, -1},
+	{-1, 0}, {1, 0},
+	{-1, 1}, {0, 1}, {1, 1},
+}
+
+// qFeatures describes an app state for linear function approximation.
+//
+// Features are:
+//   - a constant bias,
+//   - which region the cursor is in (palette, canvas, exit, other),
+//   - the cursor position,
+//   - whether the cursor is pressed and where the press started,
+//   - the selected color,
+//   - which neighboring pixels differ from the selected color,
+//   - the change in rating since the last action.
+func qFeatures(app *gui.AppState, delta float64) []float64 {
+	pos := app.Cursor.Pos
+	f := []float64{1.0}
+
+	onPalette := pos.X < gui.ImageX-gui.ButtonBuffer
+	onCanvas := pos.X >= gui.ImageX && pos.X < gui.ImageX+gui.ImageWidth
+	onExit := pos.X >= gui.ExitX && pos.Y >= gui.ExitY
+	f = append(f, b2f(onPalette), b2f(onCanvas), b2f(onExit), b2f(!onPalette && !onCanvas && !onExit))
+	f = append(f, float64(pos.X)/float64(gui.ScreenWidth), float64(pos.Y)/float64(gui.ScreenHeight))
+
+	pressPos := app.Cursor.PressPos
+	f = append(f, b2f(app.Cursor.Pressed), b2f(app.Cursor.Pressed && pressPos.X >= gui.ImageX && pressPos.X < gui.ImageX+gui.ImageWidth))
+
+	for _, c := range app.Image.Palette {
+		f = append(f, b2f(app.Color == c))
+	}
+
+	for _, n := range neighbors {
+		pt := image.Point{X: pos.X - gui.ImageX + n.X, Y: pos.Y + n.Y}
+		f = append(f, b2f(pt.In(app.Image.Bounds()) && app.Image.At(pt.X, pt.Y) != app.Color))
+	}
+
+	f = append(f, delta*qRewardScale)
+	return f
+}
+
+func b2f(b bool) float64 {
+	if b {
+		return 1.0
+	}
+	return 0.0
+}
+
+// qFeatureCount is the length of the qFeatures vector.
+var qFeatureCount = len(qFeatures(gui.NewAppState(), 0))
+
+// QLearner chooses actions with Q-learning and a linear value function.
+//
+// Train it offline with Episode, then use it as a normal Strategizer.
+type QLearner struct {
+	Rating perception.Rating `json:"-"`
+	// Weights are the linear weights of qFeatures for each of the qActions.
+	Weights [][]float64 `json:"weights"`
+	// Alpha is the learning rate.
+	Alpha float64 `json:"alpha"`
+	// Gamma is the discount of future rewards.
+	Gamma float64 `json:"gamma"`
+	// Epsilon is the probability of exploring a random action.
+	Epsilon float64 `json:"epsilon"`
+	// Rand breaks ties between actions in Strategize, if not nil. Otherwise,
+	// the global source is used.
+	Rand *rand.Rand `json:"-"`
+
+	last    float64
+	hasLast bool
+}
+
+// NewQLearner creates an untrained QLearner.
+func NewQLearner(rating perception.Rating) *QLearner {
+	q := &QLearner{Rating: rating, Alpha: 0.01, Gamma: 0.95, Epsilon: 0.1}
+	q.Weights = make([][]float64, len(qActions))
+	for i := range q.Weights {
+		q.Weights[i] = make([]float64, qFeatureCount)
+	}
+	return q
+}
+
+// LoadQLearner reads trained weights from a JSON file.
+func LoadQLearner(path string, rating perception.Rating) (*QLearner, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %s", path, err)
+	}
+	q := NewQLearner(rating)
+	if err := json.Unmarshal(b, q); err != nil {
+		return nil, fmt.Errorf("Error decoding %s: %s", path, err)
+	}
+	if len(q.Weights) != len(qActions) {
+		return nil, fmt.Errorf("Error in %s: got weights for %d actions, expected %d", path, len(q.Weights), len(qActions))
+	}
+	for _, w := range q.Weights {
+		if len(w) != qFeatureCount {
+			return nil, fmt.Errorf("Error in %s: got %d weights, expected %d", path, len(w), qFeatureCount)
+		}
+	}
+	return q, nil
+}
+
+// Save writes the weights to a JSON file.
+func (q *QLearner) Save(path string) error {
+	b, err := json.Marshal(q)
+	if err != nil {
+		return fmt.Errorf("Error encoding %s: %s", path, err)
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("Error writing %s: %s", path, err)
+	}
+	return nil
+}
+
+// value returns the expected reward of each of the qActions.
+func (q *QLearner) value(f []float64) []float64 {
+	vs := make([]float64, len(qActions))
+	for a, w := range q.Weights {
+		for i, x := range f {
+			vs[a] += w[i] * x
+		}
+	}
+	return vs
+}
+
+// greedy returns the index of the best action, breaking ties at random.
+func greedy(vs []float64, rng *rand.Rand) int {
+	var maxActs []int
+	for a, v := range vs {
+		if len(maxActs) == 0 || v > vs[maxActs[0]] {
+			maxActs = []int{a}
+		} else if v == vs[maxActs[0]] {
+			maxActs = append(maxActs, a)
+		}
+	}
+	sort.Ints(maxActs)
+	if rng == nil {
+		return maxActs[rand.Intn(len(maxActs))]
+	}
+	return maxActs[rng.Intn(len(maxActs))]
+}
+
+// Reset forgets the rating of the last frame, so that the first action of a
+// new drawing doesn't learn from the end of the previous one.
+func (q *QLearner) Reset() {
+	q.last = 0
+	q.hasLast = false
+}
+
+// Strategize chooses the action with the highest learned value.
+func (q *QLearner) Strategize(app *gui.AppState) (gui.Action, Rating) {
+	rt := q.Rating(app.Image)
+	delta := 0.0
+	if q.hasLast {
+		delta = rt - q.last
+	}
+	q.last = rt
+	q.hasLast = true
+
+	vs := q.value(qFeatures(app, delta))
+	a := greedy(vs, q.Rand)
+	return qActions[a], Rating{Rate: vs[a], Reason: &SimpleReason{"q-value"}}
+}
+
+// Episode trains the weights by drawing one picture from a blank canvas.
+//
+// The reward for each action is the change in rating. The episode ends when
+// the exit button is clicked or after maxSteps actions. It returns the final
+// rating.
+func (q *QLearner) Episode(maxSteps int, rng *rand.Rand) float64 {
+	app := gui.NewAppState()
+	rt := q.Rating(app.Image)
+	f := qFeatures(app, 0)
+	for step := 0; step < maxSteps && app.Mode == gui.MODE_DRAWING; step++ {
+		vs := q.value(f)
+		a := greedy(vs, rng)
+		if rng.Float64() < q.Epsilon {
+			a = rng.Intn(len(qActions))
+		}
+		act := qActions[a]
+		app.ApplyAction(&act)
+
+		next := q.Rating(app.Image)
+		reward := (next - rt) * qRewardScale
+		nf := qFeatures(app, next-rt)
+		target := reward
+		if app.Mode == gui.MODE_DRAWING {
+			nvs := q.value(nf)
+			target += q.Gamma * nvs[greedy(nvs, rng)]
+		}
+		diff := target - vs[a]
+		for i, x := range f {
+			q.Weights[a][i] += q.Alpha * diff * x
+		}
+		rt = next
+		f = nf
+	}
+	return rt
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package strategy
+
+import (
+	"image"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/gui"
+	"github.com/tswast/pixelsketches/village/perception"
+)
+
+func TestQFeatures(t *testing.T) {
+	app := gui.NewAppState()
+	app.Cursor.Pos.X = gui.ImageX + 3
+	app.Cursor.Pos.Y = 3
+	app.Image.Set(4, 3, palettes.PICO8_PINK)
+
+	got := qFeatures(app, 0)
+
+	if len(got) != qFeatureCount {
+		t.Fatalf("len(qFeatures(app)) => %d, expected %d", len(got), qFeatureCount)
+	}
+	// bias, palette, canvas, exit, other
+	want := []float64{1, 0, 1, 0, 0}
+	if !reflect.DeepEqual(got[:5], want) {
+		t.Errorf("qFeatures(app)[:5] => %v, expected %v", got[:5], want)
+	}
+	// Only the neighbor to the right is a different color.
+	ns := got[len(got)-1-len(neighbors) : len(got)-1]
+	wantNs := []float64{0, 0, 0, 0, 1, 0, 0, 0}
+	if !reflect.DeepEqual(ns, wantNs) {
+		t.Errorf("neighbor features => %v, expected %v", ns, wantNs)
+	}
+}
+
+func TestQLearnerEpisode(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// Every rating is higher than the last, so every action is rewarded.
+	calls := 0.0
+	q := NewQLearner(func(_ image.Image) float64 {
+		calls++
+		return calls
+	})
+	q.Epsilon = 1.0
+	q.Episode(20, rng)
+
+	changed := false
+	for _, w := range q.Weights {
+		for _, x := range w {
+			if x != 0 {
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		t.Error("Expected Episode to update the weights.")
+	}
+
+	app := gui.NewAppState()
+	act, _ := q.Strategize(app)
+	found := false
+	for _, a := range qActions {
+		if a == act {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Strategize(app) => %#v, expected one of qActions", act)
+	}
+}
+
+func TestQLearnerSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "strategy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "weights.json")
+
+	q := NewQLearner(perception.RateWholeImage)
+	q.Weights[3][2] = 0.25
+	if err := q.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadQLearner(path, perception.RateWholeImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Weights, q.Weights) {
+		t.Errorf("LoadQLearner(Save(q)).Weights => %v, expected %v", got.Weights, q.Weights)
+	}
+}
+
+func TestQLearnerReset(t *testing.T) {
+	rt := 0.0
+	q := NewQLearner(func(_ image.Image) float64 {
+		return rt
+	})
+	q.Rand = rand.New(rand.NewSource(1))
+	q.Strategize(gui.NewAppState())
+	if !q.hasLast {
+		t.Fatal("Expected Strategize to remember the rating.")
+	}
+	q.Reset()
+	if q.hasLast || q.last != 0 {
+		t.Errorf("After Reset, hasLast, last => %v, %f, expected false, 0", q.hasLast, q.last)
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package strategy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/gui"
+)
+
+// Remote asks another process to choose each action.
+//
+// The protocol is line-delimited JSON. For every frame, the artist writes one
+// RemoteRequest as a single line, such as:
+//
+//	{"frame":0,"screen":[[0,0,...],...],"cursor":{"pos":{"X":0,"Y":0},"pressed":false,"press_pos":{"X":0,"Y":0}},"color":0,"palette":["#000000",...]}
+//
+// The screen is 64 rows of 114 palette indices, exactly as drawn by
+// gui.AppState.DrawScreen. The bot replies with one RemoteResponse line, such
+// as:
+//
+//	{"pressed":true,"horizontal":1,"vertical":0,"reason":"painting"}
+//
+// Horizontal and vertical must be -1, 0 or 1. The action is applied with the
+// same gui.ApplyAction rules as every other strategy.
+//
+// If the bot fails, replies with something invalid or doesn't reply before
+// the context of StrategizeContext is done, Remote stops asking it and keeps
+// the cursor still, with the error from Err. artist.Run stops drawing once
+// Err is not nil.
+type Remote struct {
+	r     *bufio.Reader
+	w     io.Writer
+	c     io.Closer
+	frame int
+	err   error
+	mu    sync.Mutex
+}
+
+// Struct RemoteRequest is sent to a remote bot every frame.
+type RemoteRequest struct {
+	Frame int `json:"frame"`
+	// Screen is rows of palette indices.
+	Screen [][]int    `json:"screen"`
+	Cursor gui.Cursor `json:"cursor"`
+	// Color is the palette index of the selected color.
+	Color int `json:"color"`
+	// Palette is the colors of the buttons, as #rrggbb.
+	Palette []string `json:"palette"`
+}
+
+// Struct RemoteResponse is the reply from a remote bot.
+type RemoteResponse struct {
+	gui.Action
+	// Reason is an optional explanation, recorded in decision traces.
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewRemote talks to a bot which reads requests from w and writes responses
+// to r.
+func NewRemote(r io.Reader, w io.Writer) *Remote {
+	return &Remote{r: bufio.NewReader(r), w: w}
+}
+
+// Struct remoteProcess closes the pipes of a bot, then waits for it to exit.
+type remoteProcess struct {
+	cmd   *exec.Cmd
+	stdin io.Closer
+}
+
+func (p *remoteProcess) Close() error {
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
+
+// StartRemote runs a bot, which talks over its stdin and stdout.
+func StartRemote(name string, args ...string) (*Remote, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Error starting %s: %s", name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Error starting %s: %s", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Error starting %s: %s", name, err)
+	}
+	rm := NewRemote(stdout, stdin)
+	rm.c = &remoteProcess{cmd: cmd, stdin: stdin}
+	return rm, nil
+}
+
+// DialRemote connects to a bot listening on a socket, such as
+// ("tcp", "localhost:9000") or ("unix", "/tmp/bot.sock").
+func DialRemote(network, addr string) (*Remote, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to %s: %s", addr, err)
+	}
+	rm := NewRemote(conn, conn)
+	rm.c = conn
+	return rm, nil
+}
+
+// Close disconnects from the bot.
+//
+// A bot process which failed may be stuck, so it is killed rather than
+// waited for.
+func (rm *Remote) Close() error {
+	if rm.c == nil {
+		return nil
+	}
+	if p, ok := rm.c.(*remoteProcess); ok && rm.Err() != nil {
+		p.cmd.Process.Kill()
+	}
+	return rm.c.Close()
+}
+
+// Err returns the error which stopped the bot, if any.
+func (rm *Remote) Err() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	return rm.err
+}
+
+func colorIndex(pal color.Palette, c color.Color) int {
+	for i, pc := range pal {
+		if pc == c {
+			return i
+		}
+	}
+	return pal.Index(c)
+}
+
+// NewRemoteRequest describes an app state for a remote bot.
+func NewRemoteRequest(frame int, app *gui.AppState) *RemoteRequest {
+	pal := app.Image.Palette
+	req := &RemoteRequest{
+		Frame:  frame,
+		Cursor: app.Cursor,
+		Color:  colorIndex(pal, app.Color),
+	}
+	scr := app.DrawScreen()
+	b := scr.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := make([]int, b.Dx())
+		for x := b.Min.X; x < b.Max.X; x++ {
+			row[x-b.Min.X] = pal.Index(scr.At(x, y))
+		}
+		req.Screen = append(req.Screen, row)
+	}
+	for _, c := range pal {
+		req.Palette = append(req.Palette, palettes.Hex(c))
+	}
+	return req
+}
+
+func validMove(d int) bool {
+	return d >= -1 && d <= 1
+}
+
+// Struct remoteAnswer is the bot's reply to a request, or why there isn't one.
+type remoteAnswer struct {
+	resp *RemoteResponse
+	err  error
+}
+
+// ask sends a request to the bot and reads its response.
+//
+// It doesn't look at the app state, so that it can run after
+// StrategizeContext has given up waiting for it.
+func (rm *Remote) ask(b []byte) (*RemoteResponse, error) {
+	if _, err := rm.w.Write(append(b, '\n')); err != nil {
+		return nil, fmt.Errorf("Error writing request: %s", err)
+	}
+	line, err := rm.r.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("Error reading response: %s", err)
+	}
+	resp := &RemoteResponse{}
+	if err := json.Unmarshal(line, resp); err != nil {
+		return nil, fmt.Errorf("Error decoding response %q: %s", line, err)
+	}
+	if !validMove(resp.Horizontal) || !validMove(resp.Vertical) {
+		return nil, fmt.Errorf("Invalid action in response %q", line)
+	}
+	return resp, nil
+}
+
+// Strategize sends the app state to the bot and returns its action.
+func (rm *Remote) Strategize(app *gui.AppState) (gui.Action, Rating) {
+	return rm.StrategizeContext(context.Background(), app)
+}
+
+// StrategizeContext is Strategize, but stops waiting for the bot when ctx is
+// done.
+//
+// A bot which doesn't reply in time has failed, with the error from Err,
+// since its late reply would be read as the reply to the next request.
+func (rm *Remote) StrategizeContext(ctx context.Context, app *gui.AppState) (gui.Action, Rating) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	still := gui.Action{Pressed: app.Cursor.Pressed}
+	failed := Rating{Rate: -1, Reason: &SimpleReason{"remote-error"}}
+	if rm.err != nil {
+		return still, failed
+	}
+	b, err := json.Marshal(NewRemoteRequest(rm.frame, app))
+	if err != nil {
+		rm.err = fmt.Errorf("Error encoding request: %s", err)
+		return still, failed
+	}
+	rm.frame++
+
+	// Wait in another goroutine, which Close unblocks if the bot never
+	// replies.
+	ch := make(chan remoteAnswer, 1)
+	go func() {
+		resp, err := rm.ask(b)
+		ch <- remoteAnswer{resp: resp, err: err}
+	}()
+	var ans remoteAnswer
+	select {
+	case ans = <-ch:
+	case <-ctx.Done():
+		ans.err = fmt.Errorf("Error waiting for response: %s", ctx.Err())
+	}
+	if ans.err != nil {
+		rm.err = ans.err
+		return still, failed
+	}
+	reason := ans.resp.Reason
+	if reason == "" {
+		reason = "remote"
+	}
+	return ans.resp.Action, Rating{Reason: &SimpleReason{reason}}
+}
@@ -0,0 +1,130 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package strategy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/gui"
+)
+
+// fakeBot answers every request with an action.
+func fakeBot(t *testing.T, r io.Reader, w io.Writer, resp string, got chan<- *RemoteRequest) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for s.Scan() {
+		req := &RemoteRequest{}
+		if err := json.Unmarshal(s.Bytes(), req); err != nil {
+			t.Errorf("Error decoding request: %s", err)
+		}
+		got <- req
+		io.WriteString(w, resp+"\n")
+	}
+}
+
+func TestRemoteStrategize(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	got := make(chan *RemoteRequest, 1)
+	go fakeBot(t, reqR, respW, `{"pressed":true,"horizontal":-1,"vertical":1,"reason":"testing"}`, got)
+
+	rm := NewRemote(respR, reqW)
+	app := gui.NewAppState()
+	app.Cursor.Pos.X = 5
+	app.Color = palettes.PICO8_PINK
+	act, r := rm.Strategize(app)
+
+	want := gui.Action{Pressed: true, Horizontal: -1, Vertical: 1}
+	if act != want {
+		t.Errorf("Strategize(app) => %#v, expected %#v", act, want)
+	}
+	if r.Reason.Explain() != "testing" {
+		t.Errorf("Strategize(app) reason => %q, expected %q", r.Reason.Explain(), "testing")
+	}
+	req := <-got
+	if len(req.Screen) != gui.ScreenHeight || len(req.Screen[0]) != gui.ScreenWidth {
+		t.Errorf("request screen is %dx%d, expected %dx%d", len(req.Screen[0]), len(req.Screen), gui.ScreenWidth, gui.ScreenHeight)
+	}
+	if req.Cursor.Pos.X != 5 {
+		t.Errorf("request cursor => %v, expected X = 5", req.Cursor.Pos)
+	}
+	if req.Palette[req.Color] != palettes.Hex(palettes.PICO8_PINK) {
+		t.Errorf("request color => %d, expected pink", req.Color)
+	}
+	// The bottom of the palette buttons is the last color.
+	if c := req.Screen[gui.ScreenHeight-1][0]; c != len(req.Palette)-1 {
+		t.Errorf("request screen at (0, %d) => %d, expected %d", gui.ScreenHeight-1, c, len(req.Palette)-1)
+	}
+}
+
+func TestRemoteInvalidResponse(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	got := make(chan *RemoteRequest, 2)
+	go fakeBot(t, reqR, respW, `{"horizontal":2}`, got)
+
+	rm := NewRemote(respR, reqW)
+	app := gui.NewAppState()
+	act, r := rm.Strategize(app)
+	if act != (gui.Action{}) || r.Rate != -1 {
+		t.Errorf("Strategize(app) => %#v, %s, expected no action", act, r.String())
+	}
+	if rm.Err() == nil {
+		t.Error("Expected Err() after an invalid response.")
+	}
+	// The bot isn't asked again.
+	rm.Strategize(app)
+	if n := len(got); n != 1 {
+		t.Errorf("bot got %d requests, expected 1", n)
+	}
+}
+
+func TestRemoteStrategizeContext(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	defer respW.Close()
+	// The bot reads requests, but never replies.
+	go io.Copy(ioutil.Discard, reqR)
+
+	rm := NewRemote(respR, reqW)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	act, r := rm.StrategizeContext(ctx, gui.NewAppState())
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("StrategizeContext took %s, expected it to stop at the deadline", d)
+	}
+	if act != (gui.Action{}) || r.Rate != -1 {
+		t.Errorf("StrategizeContext(ctx, app) => %#v, %s, expected no action", act, r.String())
+	}
+	if rm.Err() == nil {
+		t.Error("Expected Err() after the bot didn't reply in time.")
+	}
+}
+
+func TestStartRemote(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	rm, err := StartRemote("sh", "-c", `while read l; do echo '{"horizontal":1}'; done`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	act, _ := rm.Strategize(gui.NewAppState())
+	if act != (gui.Action{Horizontal: 1}) {
+		t.Errorf("Strategize(app) => %#v, expected move right", act)
+	}
+	if err := rm.Close(); err != nil {
+		t.Errorf("Close() => %s", err)
+	}
+}
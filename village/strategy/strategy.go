@@ -6,14 +6,19 @@
 package strategy
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"image"
 	"image/color"
 	"log"
 	"math/rand"
+	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
 
+	"github.com/tswast/pixelsketches/palettes"
 	"github.com/tswast/pixelsketches/village/gui"
 	"github.com/tswast/pixelsketches/village/perception"
 )
@@ -28,50 +33,176 @@ type Strategizer interface {
 	Strategize(*gui.AppState) (gui.Action, Rating)
 }
 
-type reason interface {
-	explain() string
+// ContextStrategizer is a Strategizer which can be given a deadline.
+type ContextStrategizer interface {
+	Strategizer
+	// StrategizeContext chooses the next action before ctx is done.
+	//
+	// It is an anytime algorithm: when ctx is done, it returns the best
+	// action it has found so far.
+	StrategizeContext(context.Context, *gui.AppState) (gui.Action, Rating)
+}
+
+// StrategizeContext chooses the next action with s, honoring ctx if s is a
+// ContextStrategizer.
+func StrategizeContext(ctx context.Context, s Strategizer, app *gui.AppState) (gui.Action, Rating) {
+	if cs, ok := s.(ContextStrategizer); ok {
+		return cs.StrategizeContext(ctx, app)
+	}
+	return s.Strategize(app)
+}
+
+// Reason explains why an action was chosen.
+type Reason interface {
+	Explain() string
+}
+
+// Struct SimpleReason is a Reason without any details.
+type SimpleReason struct {
+	Reason string
 }
 
-type simpleReason struct {
-	reason string
+func (r *SimpleReason) Explain() string {
+	return r.Reason
 }
 
-func (r *simpleReason) explain() string {
-	return r.reason
+// Struct PaintReason is the pixel an action is expected to paint.
+type PaintReason struct {
+	NewColor color.Color
+	OldColor color.Color
+	// Pos is in image coordinates.
+	Pos image.Point
 }
 
-type paintReason struct {
-	newColor color.Color
-	oldColor color.Color
-	pos      image.Point
+func (r *PaintReason) Explain() string {
+	return fmt.Sprintf("painting with %v over %v @ %v", r.NewColor, r.OldColor, r.Pos)
 }
 
-func (r *paintReason) explain() string {
-	return fmt.Sprintf("painting with %v over %v @ %v", r.newColor, r.oldColor, r.pos)
+func (r *PaintReason) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		NewColor string      `json:"new_color"`
+		OldColor string      `json:"old_color"`
+		Pos      image.Point `json:"pos"`
+	}{palettes.Hex(r.NewColor), palettes.Hex(r.OldColor), r.Pos})
+}
+
+// Struct ChooseColorReason is an action expected to select a new color, then
+// paint for Reason.
+type ChooseColorReason struct {
+	Reason Reason
+}
+
+func (r *ChooseColorReason) Explain() string {
+	if r.Reason == nil {
+		return "choose-color"
+	}
+	return "choose-color-" + r.Reason.Explain()
+}
+
+// paint returns the PaintReason of a Reason, or nil if it doesn't paint.
+func paint(r Reason) *PaintReason {
+	switch r := r.(type) {
+	case *PaintReason:
+		return r
+	case *ChooseColorReason:
+		return paint(r.Reason)
+	}
+	return nil
 }
 
+// Struct Rating is the desirability of an action.
 type Rating struct {
-	rate   float64
-	dist   int
-	reason reason
+	// Rate is the expected rating of the image.
+	Rate float64
+	// Dist is the number of actions until the rating is reached.
+	Dist int
+	// Reason is why the action is desirable.
+	Reason Reason
 }
 
 func (r *Rating) String() string {
-	return fmt.Sprintf("{rate: %f dist: %d reason: %q}", r.rate, r.dist, r.reason.explain())
+	return fmt.Sprintf("{rate: %f dist: %d reason: %q}", r.Rate, r.Dist, r.explain())
+}
+
+func (r *Rating) explain() string {
+	if r.Reason == nil {
+		return ""
+	}
+	return r.Reason.Explain()
+}
+
+// MarshalJSON encodes the explanation of the reason, plus the painted pixel if
+// there is one.
+func (r Rating) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Rate   float64      `json:"rate"`
+		Dist   int          `json:"dist"`
+		Reason string       `json:"reason,omitempty"`
+		Paint  *PaintReason `json:"paint,omitempty"`
+	}{r.Rate, r.Dist, r.explain(), paint(r.Reason)})
+}
+
+// Struct Candidate is an action considered by a strategy.
+type Candidate struct {
+	Action gui.Action `json:"action"`
+	Rating Rating     `json:"rating"`
+}
+
+// Struct Ballot is the action chosen by one voter of a Plurality.
+type Ballot struct {
+	Voter  int        `json:"voter"`
+	Action gui.Action `json:"action"`
+	Rating Rating     `json:"rating"`
+}
+
+// Struct Trace explains how a strategy chose an action.
+type Trace struct {
+	Chosen gui.Action `json:"chosen"`
+	Rating Rating     `json:"rating"`
+	// Candidates are every action considered, sorted by Actions.
+	Candidates []Candidate `json:"candidates,omitempty"`
+	// Ballots are the votes of each voter, for a Plurality.
+	Ballots []Ballot `json:"ballots,omitempty"`
+}
+
+// Tracer is a Strategizer which can explain its decisions.
+type Tracer interface {
+	Strategizer
+	// StrategizeTrace chooses the next action before ctx is done, and
+	// explains why.
+	StrategizeTrace(context.Context, *gui.AppState) Trace
+}
+
+// StrategizeTrace chooses the next action with s. The trace only has the
+// chosen action and its Rating unless s is a Tracer.
+func StrategizeTrace(ctx context.Context, s Strategizer, app *gui.AppState) Trace {
+	if t, ok := s.(Tracer); ok {
+		return t.StrategizeTrace(ctx, app)
+	}
+	a, r := StrategizeContext(ctx, s, app)
+	return Trace{Chosen: a, Rating: r}
 }
 
 func imCoordToGuiCoord(pt image.Point) image.Point {
 	return image.Point{X: pt.X + gui.ImageX, Y: pt.Y}
 }
 
-type RandomWalk struct{}
+type RandomWalk struct {
+	// Rand chooses the actions, if not nil. Otherwise, the global source is
+	// used.
+	Rand *rand.Rand
+}
 
 // RandomWalk chooses the next action completely randomly.
-func (_ *RandomWalk) Strategize(_ *gui.AppState) (gui.Action, Rating) {
+func (s *RandomWalk) Strategize(_ *gui.AppState) (gui.Action, Rating) {
+	intn := rand.Intn
+	if s.Rand != nil {
+		intn = s.Rand.Intn
+	}
 	return gui.Action{
-		Horizontal: rand.Intn(3) - 1,
-		Vertical:   rand.Intn(3) - 1,
-		Pressed:    rand.Intn(2) == 1,
+		Horizontal: intn(3) - 1,
+		Vertical:   intn(3) - 1,
+		Pressed:    intn(2) == 1,
 	}, Rating{}
 }
 
@@ -93,10 +224,111 @@ func actionDistance(src, tgt image.Point) int {
 	return v
 }
 
+// Reasons which don't depend on the simulation are shared, so that
+// simulating doesn't allocate them.
+var (
+	reasonNoColors     = &SimpleReason{"no-different-colors-found"}
+	reasonNoColorRight = &SimpleReason{"no-color-to-right"}
+	reasonNoOp         = &SimpleReason{"no-op"}
+	reasonPainting     = &SimpleReason{"already-painting"}
+	reasonExit         = &SimpleReason{"exit"}
+)
+
+// paletteIndex returns the index of a color in a palette, or -1 if it isn't
+// exactly one of the palette's colors.
+func paletteIndex(p color.Palette, c color.Color) int {
+	for i, pc := range p {
+		if pc == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// copyAppState copies src into dst, reusing dst's image if it is the same
+// size.
+func copyAppState(dst, src *gui.AppState) {
+	im := dst.Image
+	*dst = *src
+	if im == nil || im.Rect != src.Image.Rect || len(im.Pix) != len(src.Image.Pix) {
+		im = image.NewPaletted(src.Image.Rect, src.Image.Palette)
+	}
+	copy(im.Pix, src.Image.Pix)
+	im.Stride = src.Image.Stride
+	im.Palette = src.Image.Palette
+	dst.Image = im
+}
+
+// Struct simulator simulates actions from a canvas.
+//
+// It reuses its scratch canvases for every simulation, so that simulating
+// doesn't allocate a canvas per action.
+type simulator struct {
+	rating perception.Rating
+	// index is where each color is on the canvas simulated from. Pooled
+	// simulators keep it, to copy into at the next step.
+	index *colorGrid
+	// from is a copy of the canvas simulated from, which paint paints on
+	// and undoes.
+	from gui.AppState
+	// applied and chosen are scratch canvases, for applying an action and
+	// for choosing a color.
+	applied, chosen gui.AppState
+	// counted wraps the canvas being rated, so that ratings share its
+	// histogram without locking.
+	counted perception.CountedImage
+}
+
+// rate rates a canvas of the simulator.
+func (s *simulator) rate(im *image.Paletted) float64 {
+	s.counted.Paletted = im
+	return s.rating(&s.counted)
+}
+
+// simulators are pooled, so that each step reuses the scratch canvases of
+// the last.
+var simulators = sync.Pool{
+	New: func() interface{} {
+		return &simulator{}
+	},
+}
+
+// Struct paintResult is the best pixel to paint, found by simulator.paint.
+//
+// Its Rating is only made for the best result, so that simulating doesn't
+// allocate a PaintReason for every color.
+type paintResult struct {
+	rate  float64
+	dist  int
+	found bool
+	// newColor paints over oldColor at pos, in image coordinates.
+	newColor, oldColor color.Color
+	pos                image.Point
+}
+
+func (r *paintResult) Rating() Rating {
+	if !r.found {
+		return Rating{Rate: -1.0, Reason: reasonNoColors}
+	}
+	return Rating{
+		Rate:   r.rate,
+		Dist:   r.dist,
+		Reason: &PaintReason{NewColor: r.newColor, OldColor: r.oldColor, Pos: r.pos},
+	}
+}
+
 // simPaint returns maximum Rating if can paint in direction, otherwise -1.
 //
 // Also, return the minimum number of actions needed to get to that position and paint.
 func simPaint(app *gui.AppState, act gui.Action, rating perception.Rating) Rating {
+	s := &simulator{rating: rating, index: newColorGrid(app.Image)}
+	r := s.paint(app, act)
+	return r.Rating()
+}
+
+// paint is simPaint, painting on app and then undoing it. The canvas of app
+// must be the one s.index was made from.
+func (s *simulator) paint(app *gui.AppState, act gui.Action) paintResult {
 	actPt := image.Point{
 		X: app.Cursor.Pos.X + act.Horizontal,
 		Y: app.Cursor.Pos.Y + act.Vertical,
@@ -135,38 +367,39 @@ func simPaint(app *gui.AppState, act gui.Action, rating perception.Rating) Ratin
 		maxY = gui.ImageHeight - 1
 	}
 
-	// Try to replace one of each color.
-	var colors map[color.Color]image.Point
-	colors = make(map[color.Color]image.Point)
-	for x := startX; x <= maxX; x++ {
-		for y := startY; y <= maxY; y++ {
-			clr := app.Image.At(x, y)
-			if clr == app.Color {
-				continue
-			}
-			// Found an existing color? Only override the point if the distance
-			// to the new point is less than the distance to the old one.
-			npt := image.Point{X: x, Y: y}
-			guiNpt := imCoordToGuiCoord(npt)
-			pt, ok := colors[clr]
-			if ok {
-				guiPt := imCoordToGuiCoord(pt)
-				if actionDistance(actPt, guiPt) <= actionDistance(actPt, guiNpt) {
-					continue
-				}
-			}
-			colors[clr] = npt
-		}
+	im := app.Image
+	selected := paletteIndex(im.Palette, app.Color)
+	paintIndex := uint8(selected)
+	if selected < 0 {
+		paintIndex = uint8(im.Palette.Index(app.Color))
+	}
+
+	// Try to replace the closest pixel of each color, keeping the first in
+	// column then row order of equally close pixels.
+	q := image.Point{X: actPt.X - gui.ImageX, Y: actPt.Y}
+	bounds := image.Rectangle{
+		Min: image.Point{X: startX, Y: startY},
+		Max: image.Point{X: maxX + 1, Y: maxY + 1},
 	}
-	max := Rating{rate: -1.0, reason: &simpleReason{"no-different-colors-found"}}
-	for clr, pt := range colors {
+	max := paintResult{rate: -1.0}
+	for c := range im.Palette {
+		if c == selected {
+			continue
+		}
+		pt, ptDist, found := s.index.nearest(uint8(c), q, bounds)
+		if !found {
+			continue
+		}
+
 		// Set the color, rate, then undo. (Should be faster than copying and applying actions.)
-		app.Image.Set(pt.X, pt.Y, app.Color)
-		rate := rating(app.Image)
-		app.Image.Set(pt.X, pt.Y, clr)
+		i := im.PixOffset(pt.X, pt.Y)
+		old := im.Pix[i]
+		im.Pix[i] = paintIndex
+		rate := s.rate(im)
+		im.Pix[i] = old
 
 		// Distance to move from cursor to point, including this action.
-		dist := actionDistance(actPt, imCoordToGuiCoord(pt)) + 1
+		dist := ptDist + 1
 		// Special cases are needed for distance == 1.
 		if dist == 1 {
 			if act.Pressed && app.Cursor.Pressed &&
@@ -181,9 +414,14 @@ func simPaint(app *gui.AppState, act gui.Action, rating perception.Rating) Ratin
 		}
 
 		if (rate == max.rate && dist < max.dist) || rate > max.rate {
-			max.rate = rate
-			max.dist = dist
-			max.reason = &paintReason{newColor: app.Color, oldColor: clr, pos: pt}
+			max = paintResult{
+				rate:     rate,
+				dist:     dist,
+				found:    true,
+				newColor: app.Color,
+				oldColor: im.Palette[c],
+				pos:      pt,
+			}
 		}
 	}
 	return max
@@ -193,10 +431,17 @@ func simPaint(app *gui.AppState, act gui.Action, rating perception.Rating) Ratin
 //
 // Also returns the number of actions needed to select the color then paint.
 func simChooseColor(app *gui.AppState, act gui.Action, rating perception.Rating) Rating {
+	s := &simulator{rating: rating, index: newColorGrid(app.Image)}
+	return s.chooseColor(app, act)
+}
+
+// chooseColor is simChooseColor. The canvas of app must be the one s.index
+// was made from.
+func (s *simulator) chooseColor(app *gui.AppState, act gui.Action) Rating {
 	// When can't choose some color?
 	// When going right and to the right of the buttons.
 	if act.Horizontal > 0 && app.Cursor.Pos.X >= gui.ImageX-gui.ButtonBuffer {
-		return Rating{rate: -1, reason: &simpleReason{"no-color-to-right"}}
+		return Rating{Rate: -1, Reason: reasonNoColorRight}
 	}
 	actPt := image.Point{X: app.Cursor.Pos.X + act.Horizontal, Y: app.Cursor.Pos.Y + act.Vertical}
 	drawAct := gui.Action{Horizontal: 1}
@@ -211,8 +456,11 @@ func simChooseColor(app *gui.AppState, act gui.Action, rating perception.Rating)
 	}
 
 	// Which colors could we pick?
-	max := Rating{rate: -1}
-	simApp := gui.CopyAppState(app)
+	max := Rating{Rate: -1}
+	var best paintResult
+	chosen := false
+	simApp := &s.chosen
+	copyAppState(simApp, app)
 	// Apply the action to be certain the latest color is chosen.
 	simApp.ApplyAction(&act)
 	for c := cMin; c <= cMax; c++ {
@@ -232,7 +480,7 @@ func simChooseColor(app *gui.AppState, act gui.Action, rating perception.Rating)
 			simApp.Cursor.Pos.Y = c*gui.ButtonHeight + gui.ButtonHeight/2
 		}
 
-		v := simPaint(simApp, drawAct, rating)
+		v := s.paint(simApp, drawAct)
 		rate := v.rate
 		// One action for current action +
 		// Distance from cursor after current action to button and click +
@@ -246,17 +494,20 @@ func simChooseColor(app *gui.AppState, act gui.Action, rating perception.Rating)
 			// Remove the extra action if already clicked the button.
 			dist -= 1
 		}
-		if (rate == max.rate && dist < max.dist) || rate > max.rate {
-			max.rate = rate
-			max.dist = dist
-			max.reason = v.reason
+		if (rate == max.Rate && dist < max.Dist) || rate > max.Rate {
+			max.Rate = rate
+			max.Dist = dist
+			best, chosen = v, true
 		}
 	}
+	if chosen {
+		max.Reason = best.Rating().Reason
+	}
 	return max
 }
 
-// simExit returns Rating if can exit in direction, otherwise -1.
-func simExit(app *gui.AppState, act gui.Action, rating perception.Rating) (float64, int) {
+// exit returns Rating if can exit in direction, otherwise -1.
+func (s *simulator) exit(app *gui.AppState, act gui.Action) (float64, int) {
 	rate := -1.0
 	// Going right.
 	if (act.Horizontal > 0 && act.Vertical == 0) ||
@@ -268,17 +519,22 @@ func simExit(app *gui.AppState, act gui.Action, rating perception.Rating) (float
 		(app.Cursor.Pos.X > gui.ExitX && app.Cursor.Pos.Y > gui.ExitY) {
 		// The Rating for choosing the exit action is whatever Rating the image
 		// would get now.
-		app.ApplyAction(&act)
-		rate = rating(app.Image)
+		simApp := &s.applied
+		copyAppState(simApp, app)
+		simApp.ApplyAction(&act)
+		rate = s.rate(simApp.Image)
 	}
 	// Use distance -1 so that exit is chosen before any other equivalent action.
 	return rate, -1
 }
 
-// simAction returns the maximum expected Rating for a given action & direction.
+// action returns the maximum expected Rating for a given action & direction.
+//
+// If ctx is done, it returns the maximum of the simulations done so far.
 //
-// Modifies app, so send a copy.
-func simAction(app *gui.AppState, act gui.Action, rating perception.Rating) Rating {
+// It paints on app, but undoes it. The canvas of app must be the one s.index
+// was made from.
+func (s *simulator) action(ctx context.Context, app *gui.AppState, act gui.Action) Rating {
 	// Can't move left from the left edge of the screen.
 	if (app.Cursor.Pos.X <= 0 && act.Horizontal < 0) ||
 		// Can't move right from the right edge of the screen.
@@ -290,27 +546,28 @@ func simAction(app *gui.AppState, act gui.Action, rating perception.Rating) Rati
 		// There is nothing to click in the upper-right quadrant once outside of the image.
 		(app.Cursor.Pos.X >= gui.ImageX+gui.ImageWidth && app.Cursor.Pos.Y <= gui.ExitY && act.Horizontal > 0 && act.Vertical < 0) {
 		// Return -1 to discourage from picking this action.
-		return Rating{rate: -1, dist: 0, reason: &simpleReason{"no-op"}}
+		return Rating{Rate: -1, Dist: 0, Reason: reasonNoOp}
 	}
 
 	// Already painting this action? Return the new Rating. Don't simulate
 	// anything else since already painted once for this action.
 	if act.Pressed {
-		simApp := gui.CopyAppState(app)
+		simApp := &s.applied
+		copyAppState(simApp, app)
 		simApp.ApplyAction(&act)
 		imX := simApp.Cursor.Pos.X - gui.ImageX
 		imY := simApp.Cursor.Pos.Y
 		if imX >= 0 && imX < gui.ImageWidth &&
-			simApp.Image.At(imX, imY) != app.Image.At(imX, imY) {
+			simApp.Image.ColorIndexAt(imX, imY) != app.Image.ColorIndexAt(imX, imY) {
 			return Rating{
-				rate:   rating(simApp.Image),
-				dist:   1,
-				reason: &simpleReason{"already-painting"},
+				Rate:   s.rate(simApp.Image),
+				Dist:   1,
+				Reason: reasonPainting,
 			}
 		}
 	}
 
-	max := Rating{rate: -1}
+	max := Rating{Rate: -1}
 
 	// What are the actions that are possible in this direction? Always end
 	// on a paint or exit button so we can see how the Rating will change.
@@ -318,25 +575,31 @@ func simAction(app *gui.AppState, act gui.Action, rating perception.Rating) Rati
 	// stays exactly the same.
 
 	// Can we reach the exit button in the lower-right corner?
-	rate, dist := simExit(gui.CopyAppState(app), act, rating)
-	if (rate == max.rate && dist < max.dist) || rate > max.rate {
-		max.rate = rate
-		max.dist = dist
-		max.reason = &simpleReason{"exit"}
+	rate, dist := s.exit(app, act)
+	if (rate == max.Rate && dist < max.Dist) || rate > max.Rate {
+		max.Rate = rate
+		max.Dist = dist
+		max.Reason = reasonExit
+	}
+	if ctx.Err() != nil {
+		return max
 	}
 
 	// Can we paint the selected color somewhere different?
-	v := simPaint(gui.CopyAppState(app), act, rating)
-	if (v.rate == max.rate && v.dist < max.dist) || v.rate > max.rate {
-		max = v
+	p := s.paint(app, act)
+	if (p.rate == max.Rate && p.dist < max.Dist) || p.rate > max.Rate {
+		max = p.Rating()
+	}
+	if ctx.Err() != nil {
+		return max
 	}
 
 	// Can we pick a new color and paint somewhere with that?
-	v = simChooseColor(gui.CopyAppState(app), act, rating)
-	if (v.rate == max.rate && v.dist < max.dist) || v.rate > max.rate {
-		max.rate = v.rate
-		max.dist = v.dist
-		max.reason = &simpleReason{"choose-color-" + v.reason.explain()}
+	v := s.chooseColor(app, act)
+	if (v.Rate == max.Rate && v.Dist < max.Dist) || v.Rate > max.Rate {
+		max.Rate = v.Rate
+		max.Dist = v.Dist
+		max.Reason = &ChooseColorReason{v.Reason}
 	}
 	return max
 }
@@ -379,84 +642,250 @@ func (s Actions) Less(i, j int) bool {
 	return s[i].Horizontal < s[j].Horizontal
 }
 
+// idealActions are the actions Ideal simulates, sorted by Actions.
+var idealActions = func() []gui.Action {
+	var acts []gui.Action
+	// The directions are sorted, and unpressed actions sort first.
+	for _, pressed := range []bool{false, true} {
+		for _, dir := range directions {
+			acts = append(acts, gui.Action{Horizontal: dir.h, Vertical: dir.v, Pressed: pressed})
+		}
+	}
+	return acts
+}()
+
+// Ideal simulates every action and chooses the one with the highest Rating.
+//
+// It is safe for concurrent use, but must not be copied after its first use,
+// since it keeps where each color is on the canvas between steps.
 type Ideal struct {
 	Rating perception.Rating
+	// Rand breaks ties between actions, if not nil. Otherwise, the global
+	// source is used. Give each Plurality voter its own to make the vote
+	// deterministic, since voters run at the same time. Ideal only uses it
+	// while holding its lock, so it must not be shared with anything else.
+	Rand *rand.Rand
+	// Workers is how many actions are simulated at the same time. If it is
+	// 0, it is runtime.GOMAXPROCS.
+	Workers int
+
+	// mu guards grid, which is where each color was on the canvas of the
+	// last step. Each step updates it with the pixels which changed, rather
+	// than indexing the canvas again. It also guards last, the index in
+	// idealActions of the last action chosen, which is simulated first.
+	mu   sync.Mutex
+	grid *colorGrid
+	last int
 }
 
 // Ideal chooses the next action which has the highest expected overall Rating.
 func (s *Ideal) Strategize(app *gui.AppState) (gui.Action, Rating) {
-	var results map[gui.Action]Rating
-	results = make(map[gui.Action]Rating)
+	return s.StrategizeContext(context.Background(), app)
+}
 
-	// Check each possible action and do the one with the highest expected value.
-	var wg sync.WaitGroup
-	lock := sync.Mutex{}
-	for _, dir := range directions {
-		wg.Add(2)
-		a := gui.Action{
-			Horizontal: dir.h,
-			Vertical:   dir.v,
+// StrategizeContext chooses the best action among those simulated before ctx
+// is done.
+//
+// At least one action which moves the cursor is simulated in full, even if
+// ctx is already done, so that there is always a real choice.
+func (s *Ideal) StrategizeContext(ctx context.Context, app *gui.AppState) (gui.Action, Rating) {
+	t := s.StrategizeTrace(ctx, app)
+	return t.Chosen, t.Rating
+}
+
+// Struct simulated is the Rating of the action at index i of idealActions.
+type simulated struct {
+	i      int
+	rating Rating
+}
+
+// simulate simulates idealActions with a bounded pool of workers, each with
+// its own simulator, and sends the results to ch until ctx is done and some
+// action has been rated.
+func (s *Ideal) simulate(ctx context.Context, app *gui.AppState, ch chan<- simulated) {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(idealActions) {
+		workers = len(idealActions)
+	}
+
+	// Give each worker its own copy of the canvas and of where each color
+	// is on it, so that workers still running after ctx is done don't race
+	// with changes to app or with the next step.
+	sims := make([]*simulator, workers)
+	s.mu.Lock()
+	if s.grid == nil {
+		s.grid = newColorGrid(app.Image)
+	} else {
+		s.grid.update(app.Image)
+	}
+	// Start from the last action chosen, so that when time is short the
+	// artist carries on the way it was going.
+	start := s.last
+	for w := range sims {
+		sim := simulators.Get().(*simulator)
+		sim.rating = s.Rating
+		if sim.index == nil {
+			sim.index = &colorGrid{}
 		}
-		calculateResult := func(a gui.Action) {
-			defer wg.Done()
-			v := simAction(gui.CopyAppState(app), a, s.Rating)
-			// Write results.
-			lock.Lock()
-			results[a] = v
-			lock.Unlock()
+		sim.index.copyFrom(s.grid)
+		copyAppState(&sim.from, app)
+		sims[w] = sim
+	}
+	s.mu.Unlock()
+
+	next := int32(-1)
+	// rated is 1 once an action other than a no-op has been simulated. Until
+	// then, workers ignore ctx.
+	rated := int32(0)
+	for _, sim := range sims {
+		go func(sim *simulator) {
+			for {
+				first := atomic.LoadInt32(&rated) == 0
+				if !first && ctx.Err() != nil {
+					break
+				}
+				k := int(atomic.AddInt32(&next, 1))
+				if k >= len(idealActions) {
+					break
+				}
+				i := (start + k) % len(idealActions)
+				actx := ctx
+				if first {
+					actx = context.Background()
+				}
+				r := sim.action(actx, &sim.from, idealActions[i])
+				if r.Reason != reasonNoOp {
+					atomic.StoreInt32(&rated, 1)
+				}
+				ch <- simulated{i: i, rating: r}
+			}
+			sim.rating = nil
+			simulators.Put(sim)
+		}(sim)
+	}
+}
+
+// StrategizeTrace is StrategizeContext, with every simulated action as a
+// candidate.
+func (s *Ideal) StrategizeTrace(ctx context.Context, app *gui.AppState) Trace {
+	// Check each possible action and do the one with the highest expected value.
+	ch := make(chan simulated, len(idealActions))
+	s.simulate(ctx, app, ch)
+
+	// Collect results until every action is simulated or time is up. The
+	// deadline is only watched once an action which moves the cursor is
+	// rated, as simulate keeps going until then.
+	results := make([]Rating, len(idealActions))
+	done := make([]bool, len(idealActions))
+	n := 0
+	var deadline <-chan struct{}
+collect:
+	for n < len(idealActions) {
+		select {
+		case r := <-ch:
+			results[r.i] = r.rating
+			done[r.i] = true
+			n++
+			if r.rating.Reason != reasonNoOp {
+				deadline = ctx.Done()
+			}
+		case <-deadline:
+			break collect
 		}
-		go calculateResult(a)
-		a.Pressed = true
-		go calculateResult(a)
 	}
-	wg.Wait()
 
-	var maxActs []gui.Action
-	max := Rating{rate: -1.0}
-	for k, v := range results {
-		if (v.rate == max.rate && v.dist < max.dist) || v.rate > max.rate {
+	var t Trace
+	// The actions are already sorted, so the candidates and the actions
+	// with the maximum Rating are too.
+	t.Candidates = make([]Candidate, 0, n)
+	maxActs := make([]gui.Action, 0, n)
+	max := Rating{Rate: -1.0}
+	for i, a := range idealActions {
+		if !done[i] {
+			continue
+		}
+		v := results[i]
+		t.Candidates = append(t.Candidates, Candidate{Action: a, Rating: v})
+		if (v.Rate == max.Rate && v.Dist < max.Dist) || v.Rate > max.Rate {
 			max = v
-			maxActs = []gui.Action{k}
-		} else if v.rate == max.rate && v.dist == max.dist {
-			maxActs = append(maxActs, k)
+			maxActs = append(maxActs[:0], a)
+		} else if v.Rate == max.Rate && v.Dist == max.Dist {
+			maxActs = append(maxActs, a)
 		}
 	}
-	sort.Sort(Actions(maxActs))
+	t.Rating = max
 	if len(maxActs) == 0 {
 		log.Printf("Oops. I didn't find a maximum action.\n")
-		return gui.Action{}, max
+		return t
 	}
-	maxAct := maxActs[rand.Intn(len(maxActs))]
-	return maxAct, max
+	// Rand isn't safe for concurrent use, so it is also guarded by mu.
+	s.mu.Lock()
+	if s.Rand != nil {
+		t.Chosen = maxActs[s.Rand.Intn(len(maxActs))]
+	} else {
+		t.Chosen = maxActs[rand.Intn(len(maxActs))]
+	}
+	for i, a := range idealActions {
+		if a == t.Chosen {
+			s.last = i
+		}
+	}
+	s.mu.Unlock()
+	return t
 }
 
 type Plurality struct {
 	Voters []*Ideal
+	// Rand breaks ties between the most voted actions, if not nil.
+	// Otherwise, the global source is used.
+	Rand *rand.Rand
 }
 
 func (s *Plurality) Strategize(app *gui.AppState) (gui.Action, Rating) {
+	return s.StrategizeContext(context.Background(), app)
+}
+
+// StrategizeContext counts the votes of every voter, each of which chooses
+// its best action before ctx is done.
+func (s *Plurality) StrategizeContext(ctx context.Context, app *gui.AppState) (gui.Action, Rating) {
+	t := s.StrategizeTrace(ctx, app)
+	return t.Chosen, t.Rating
+}
+
+// StrategizeTrace is StrategizeContext, with the number of votes for each
+// action as candidates and each voter's ballot.
+func (s *Plurality) StrategizeTrace(ctx context.Context, app *gui.AppState) Trace {
 	vs := make(map[gui.Action]int)
+	var t Trace
+	t.Ballots = make([]Ballot, len(s.Voters))
 
 	var wg sync.WaitGroup
 	lock := sync.Mutex{}
-	for _, v := range s.Voters {
+	for i, v := range s.Voters {
 		wg.Add(1)
-		vote := func(v *Ideal) {
+		vote := func(i int, v *Ideal) {
 			defer wg.Done()
-			a, _ := v.Strategize(app)
+			vt := v.StrategizeTrace(ctx, app)
+			a := vt.Chosen
 			// Increment votes for that voter's top choice.
 			lock.Lock()
 			vs[a] = vs[a] + 1
+			t.Ballots[i] = Ballot{Voter: i, Action: a, Rating: vt.Rating}
 			lock.Unlock()
 		}
-		go vote(v)
+		go vote(i, v)
 	}
 	wg.Wait()
 
 	// Find the action with the top votes.
+	var acts []gui.Action
 	var ma []gui.Action
 	m := -1
 	for a, c := range vs {
+		acts = append(acts, a)
 		if c > m {
 			ma = []gui.Action{a}
 			m = c
@@ -464,13 +893,23 @@ func (s *Plurality) Strategize(app *gui.AppState) (gui.Action, Rating) {
 			ma = append(ma, a)
 		}
 	}
+	sort.Sort(Actions(acts))
+	for _, a := range acts {
+		t.Candidates = append(t.Candidates, Candidate{Action: a, Rating: Rating{Rate: float64(vs[a]), Reason: &SimpleReason{"votes"}}})
+	}
 	// Choose from top voted choices at random.
 	// This keeps the votes deterministic for a given random seed.
 	sort.Sort(Actions(ma))
 	if len(ma) == 0 {
 		log.Printf("Oops. I didn't find a maximum action.\n")
-		return gui.Action{}, Rating{rate: float64(m), reason: &simpleReason{"no max votes"}}
+		t.Rating = Rating{Rate: float64(m), Reason: &SimpleReason{"no max votes"}}
+		return t
+	}
+	if s.Rand != nil {
+		t.Chosen = ma[s.Rand.Intn(len(ma))]
+	} else {
+		t.Chosen = ma[rand.Intn(len(ma))]
 	}
-	a := ma[rand.Intn(len(ma))]
-	return a, Rating{rate: float64(m), reason: &simpleReason{"votes"}}
+	t.Rating = Rating{Rate: float64(m), Reason: &SimpleReason{"votes"}}
+	return t
 }
@@ -5,9 +5,14 @@
 package strategy
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"image"
+	"math/rand"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/tswast/pixelsketches/palettes"
 	"github.com/tswast/pixelsketches/village/gui"
@@ -15,7 +20,7 @@ import (
 )
 
 func checkRating(t *testing.T, fn string, app *gui.AppState, im string, action gui.Action, got Rating, expected *Rating) {
-	if got.reason.explain() != expected.reason.explain() || got.dist != expected.dist {
+	if got.Reason.Explain() != expected.Reason.Explain() || got.Dist != expected.Dist {
 		press := ""
 		if app.Cursor.Pressed {
 			press = fmt.Sprintf(" [pressed @ %v]", app.Cursor.PressPos)
@@ -27,10 +32,10 @@ func checkRating(t *testing.T, fn string, app *gui.AppState, im string, action g
 			app.Cursor.Pos,
 			press,
 			action,
-			got.dist,
-			got.reason.explain(),
-			expected.dist,
-			expected.reason.explain())
+			got.Dist,
+			got.Reason.Explain(),
+			expected.Dist,
+			expected.Reason.Explain())
 	}
 }
 
@@ -39,7 +44,7 @@ func checkSimPaint(t *testing.T, app *gui.AppState, im string, action gui.Action
 }
 
 func checkNoColors(t *testing.T, app *gui.AppState, im string, action gui.Action, got Rating) {
-	checkSimPaint(t, app, im, action, got, &Rating{rate: -1, dist: 0, reason: &simpleReason{"no-different-colors-found"}})
+	checkSimPaint(t, app, im, action, got, &Rating{Rate: -1, Dist: 0, Reason: &SimpleReason{"no-different-colors-found"}})
 }
 
 var (
@@ -158,7 +163,7 @@ func TestSimPaintAtPoint(t *testing.T) {
 		"pink-block, top-middle black",
 		toUp,
 		got,
-		&Rating{dist: 2, reason: &paintReason{newColor: palettes.PICO8_PINK, oldColor: palettes.PICO8_BLACK, pos: image.Point{X: 3, Y: 1}}})
+		&Rating{Dist: 2, Reason: &PaintReason{NewColor: palettes.PICO8_PINK, OldColor: palettes.PICO8_BLACK, Pos: image.Point{X: 3, Y: 1}}})
 
 	app = newAppStatePinkBlock(3, 3)
 	app.Image.Set(4, 1, palettes.PICO8_BLACK)
@@ -169,7 +174,7 @@ func TestSimPaintAtPoint(t *testing.T) {
 		"pink-block, top-right black",
 		toUpRight,
 		got,
-		&Rating{dist: 2, reason: &paintReason{newColor: palettes.PICO8_PINK, oldColor: palettes.PICO8_BLACK, pos: image.Point{X: 4, Y: 1}}})
+		&Rating{Dist: 2, Reason: &PaintReason{NewColor: palettes.PICO8_PINK, OldColor: palettes.PICO8_BLACK, Pos: image.Point{X: 4, Y: 1}}})
 
 	app = newAppStatePinkBlock(3, 3)
 	app.Image.Set(5, 3, palettes.PICO8_BLACK)
@@ -180,7 +185,7 @@ func TestSimPaintAtPoint(t *testing.T) {
 		"pink-block, middle-right black",
 		toRight,
 		got,
-		&Rating{dist: 2, reason: &paintReason{newColor: palettes.PICO8_PINK, oldColor: palettes.PICO8_BLACK, pos: image.Point{X: 5, Y: 3}}})
+		&Rating{Dist: 2, Reason: &PaintReason{NewColor: palettes.PICO8_PINK, OldColor: palettes.PICO8_BLACK, Pos: image.Point{X: 5, Y: 3}}})
 
 	app = newAppStatePinkBlock(3, 3)
 	app.Image.Set(4, 5, palettes.PICO8_BLACK)
@@ -191,7 +196,7 @@ func TestSimPaintAtPoint(t *testing.T) {
 		"pink-block, bottom-right black",
 		toDownRight,
 		got,
-		&Rating{dist: 2, reason: &paintReason{newColor: palettes.PICO8_PINK, oldColor: palettes.PICO8_BLACK, pos: image.Point{X: 4, Y: 5}}})
+		&Rating{Dist: 2, Reason: &PaintReason{NewColor: palettes.PICO8_PINK, OldColor: palettes.PICO8_BLACK, Pos: image.Point{X: 4, Y: 5}}})
 
 	app = newAppStatePinkBlock(3, 3)
 	app.Image.Set(3, 5, palettes.PICO8_BLACK)
@@ -202,7 +207,7 @@ func TestSimPaintAtPoint(t *testing.T) {
 		"pink-block, bottom-middle black",
 		toDown,
 		got,
-		&Rating{dist: 2, reason: &paintReason{newColor: palettes.PICO8_PINK, oldColor: palettes.PICO8_BLACK, pos: image.Point{X: 3, Y: 5}}})
+		&Rating{Dist: 2, Reason: &PaintReason{NewColor: palettes.PICO8_PINK, OldColor: palettes.PICO8_BLACK, Pos: image.Point{X: 3, Y: 5}}})
 
 	app = newAppStatePinkBlock(3, 3)
 	app.Image.Set(2, 5, palettes.PICO8_BLACK)
@@ -213,7 +218,7 @@ func TestSimPaintAtPoint(t *testing.T) {
 		"pink-block, bottom-left black",
 		toDownLeft,
 		got,
-		&Rating{dist: 2, reason: &paintReason{newColor: palettes.PICO8_PINK, oldColor: palettes.PICO8_BLACK, pos: image.Point{X: 2, Y: 5}}})
+		&Rating{Dist: 2, Reason: &PaintReason{NewColor: palettes.PICO8_PINK, OldColor: palettes.PICO8_BLACK, Pos: image.Point{X: 2, Y: 5}}})
 
 	app = newAppStatePinkBlock(3, 3)
 	app.Image.Set(1, 3, palettes.PICO8_BLACK)
@@ -224,7 +229,7 @@ func TestSimPaintAtPoint(t *testing.T) {
 		"pink-block, middle-left black",
 		toLeft,
 		got,
-		&Rating{dist: 2, reason: &paintReason{newColor: palettes.PICO8_PINK, oldColor: palettes.PICO8_BLACK, pos: image.Point{X: 1, Y: 3}}})
+		&Rating{Dist: 2, Reason: &PaintReason{NewColor: palettes.PICO8_PINK, OldColor: palettes.PICO8_BLACK, Pos: image.Point{X: 1, Y: 3}}})
 
 	app = newAppStatePinkBlock(3, 3)
 	app.Image.Set(2, 1, palettes.PICO8_BLACK)
@@ -235,7 +240,7 @@ func TestSimPaintAtPoint(t *testing.T) {
 		"pink-block, top-left black",
 		toUpLeft,
 		got,
-		&Rating{dist: 2, reason: &paintReason{newColor: palettes.PICO8_PINK, oldColor: palettes.PICO8_BLACK, pos: image.Point{X: 2, Y: 1}}})
+		&Rating{Dist: 2, Reason: &PaintReason{NewColor: palettes.PICO8_PINK, OldColor: palettes.PICO8_BLACK, Pos: image.Point{X: 2, Y: 1}}})
 
 	// Block on right side of screen.
 	app = newAppStatePinkBlock(60, 3)
@@ -249,7 +254,7 @@ func TestSimPaintAtPoint(t *testing.T) {
 		"pink-block @ (60, 3), middle-right black",
 		toRight,
 		got,
-		&Rating{dist: 2, reason: &paintReason{newColor: palettes.PICO8_PINK, oldColor: palettes.PICO8_BLACK, pos: image.Point{X: 62, Y: 3}}})
+		&Rating{Dist: 2, Reason: &PaintReason{NewColor: palettes.PICO8_PINK, OldColor: palettes.PICO8_BLACK, Pos: image.Point{X: 62, Y: 3}}})
 
 	// Painting 1 action away, but not this action, so 2 or more actions away.
 	app = newAppStatePinkBlock(3, 3)
@@ -263,7 +268,7 @@ func TestSimPaintAtPoint(t *testing.T) {
 		"pink-block, top-left dist=1 black",
 		toUp,
 		got,
-		&Rating{dist: 2, reason: &paintReason{newColor: palettes.PICO8_PINK, oldColor: palettes.PICO8_BLACK, pos: image.Point{X: 2, Y: 2}}})
+		&Rating{Dist: 2, Reason: &PaintReason{NewColor: palettes.PICO8_PINK, OldColor: palettes.PICO8_BLACK, Pos: image.Point{X: 2, Y: 2}}})
 
 	// Distance 1 away, but not pressing.
 	app = newAppStatePinkBlock(3, 3)
@@ -277,7 +282,7 @@ func TestSimPaintAtPoint(t *testing.T) {
 		"pink-block, top-middle dist=1, black",
 		toUp,
 		got,
-		&Rating{dist: 2, reason: &paintReason{newColor: palettes.PICO8_PINK, oldColor: palettes.PICO8_BLACK, pos: image.Point{X: 3, Y: 2}}})
+		&Rating{Dist: 2, Reason: &PaintReason{NewColor: palettes.PICO8_PINK, OldColor: palettes.PICO8_BLACK, Pos: image.Point{X: 3, Y: 2}}})
 
 	// Distance 1 away, including this action, but have to release then paint again. So, actually distance 3.
 	app = newAppStatePinkBlock(3, 3)
@@ -296,7 +301,7 @@ func TestSimPaintAtPoint(t *testing.T) {
 		"pink-block, top-middle dist=1, black",
 		act,
 		got,
-		&Rating{dist: 3, reason: &paintReason{newColor: palettes.PICO8_PINK, oldColor: palettes.PICO8_BLACK, pos: image.Point{X: 3, Y: 2}}})
+		&Rating{Dist: 3, Reason: &PaintReason{NewColor: palettes.PICO8_PINK, OldColor: palettes.PICO8_BLACK, Pos: image.Point{X: 3, Y: 2}}})
 	app.Cursor.Pressed = false
 
 	// Distance 1 away, including this action. (Same as "still painting")
@@ -312,7 +317,7 @@ func TestSimPaintAtPoint(t *testing.T) {
 		"pink-block, top-middle dist=1, black",
 		act,
 		got,
-		&Rating{dist: 1, reason: &paintReason{newColor: palettes.PICO8_PINK, oldColor: palettes.PICO8_BLACK, pos: image.Point{X: 3, Y: 2}}})
+		&Rating{Dist: 1, Reason: &PaintReason{NewColor: palettes.PICO8_PINK, OldColor: palettes.PICO8_BLACK, Pos: image.Point{X: 3, Y: 2}}})
 }
 
 func checkSimChooseColor(t *testing.T, app *gui.AppState, im string, action gui.Action, got Rating, expected *Rating) {
@@ -327,7 +332,8 @@ func TestSimChooseColor(t *testing.T) {
 	app.Color = palettes.PICO8_PINK
 	act := toLeft
 	got := simChooseColor(app, act, func(im image.Image) float64 {
-		return perception.RateImage(im, palettes.PICO8_BLACK, 1.0)
+		b := im.Bounds()
+		return perception.RateImage(b.Dx()*b.Dy(), perception.CountColors(im)[palettes.PICO8_BLACK], 1.0)
 	})
 	checkSimChooseColor(
 		t,
@@ -337,11 +343,11 @@ func TestSimChooseColor(t *testing.T) {
 		got,
 		&Rating{
 			// 3 from clicking new color. 6 to paint pixel from button.
-			dist: 3 + 6,
-			reason: &paintReason{
-				newColor: palettes.PICO8_BLACK,
-				oldColor: palettes.PICO8_ORANGE,
-				pos:      image.Point{X: 3, Y: gui.ButtonHeight / 2}}})
+			Dist: 3 + 6,
+			Reason: &PaintReason{
+				NewColor: palettes.PICO8_BLACK,
+				OldColor: palettes.PICO8_ORANGE,
+				Pos:      image.Point{X: 3, Y: gui.ButtonHeight / 2}}})
 
 	// Moving up or down, which doesn't get you closer to the button.
 	app = gui.NewAppState()
@@ -351,7 +357,8 @@ func TestSimChooseColor(t *testing.T) {
 	app.Color = palettes.PICO8_PINK
 	act = toUp
 	got = simChooseColor(app, act, func(im image.Image) float64 {
-		return perception.RateImage(im, palettes.PICO8_BLACK, 1.0)
+		b := im.Bounds()
+		return perception.RateImage(b.Dx()*b.Dy(), perception.CountColors(im)[palettes.PICO8_BLACK], 1.0)
 	})
 	checkSimChooseColor(
 		t,
@@ -361,9 +368,135 @@ func TestSimChooseColor(t *testing.T) {
 		got,
 		&Rating{
 			// 1 for going wrong direction. 3 from clicking new color. 6 to paint pixel from button.
-			dist: 1 + 3 + 6,
-			reason: &paintReason{
-				newColor: palettes.PICO8_BLACK,
-				oldColor: palettes.PICO8_ORANGE,
-				pos:      image.Point{X: 3, Y: gui.ButtonHeight / 2}}})
+			Dist: 1 + 3 + 6,
+			Reason: &PaintReason{
+				NewColor: palettes.PICO8_BLACK,
+				OldColor: palettes.PICO8_ORANGE,
+				Pos:      image.Point{X: 3, Y: gui.ButtonHeight / 2}}})
+}
+
+// checkMoves checks that an action chosen after the deadline moves the
+// cursor, so the artist doesn't sit still when time is short.
+func checkMoves(t *testing.T, name string, app *gui.AppState, a gui.Action) {
+	var moved gui.AppState
+	copyAppState(&moved, app)
+	moved.ApplyAction(&a)
+	if moved.Cursor.Pos == app.Cursor.Pos {
+		t.Errorf("%s => %#v, expected an action which moves the cursor from %v", name, a, app.Cursor.Pos)
+	}
+}
+
+func TestIdealDeadline(t *testing.T) {
+	app := gui.NewAppState()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := &Ideal{Rating: perception.RateWholeImage}
+
+	got := s.StrategizeTrace(ctx, app)
+	if got.Rating.Reason == nil || got.Rating.Reason == reasonNoOp || got.Rating.Rate < 0 {
+		t.Errorf("StrategizeTrace(done) => %s, expected a simulated action", got.Rating.String())
+	}
+	if len(got.Candidates) == 0 {
+		t.Error("StrategizeTrace(done) => no candidates, expected at least one")
+	}
+	checkMoves(t, "StrategizeTrace(done)", app, got.Chosen)
+}
+
+func TestPluralityDeadline(t *testing.T) {
+	app := gui.NewAppState()
+	app.Cursor.Pos = image.Point{X: gui.ImageX + 10, Y: 10}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := &Plurality{Voters: []*Ideal{
+		&Ideal{Rating: perception.RateWholeImage},
+		&Ideal{Rating: perception.RateBlack},
+	}}
+
+	start := time.Now()
+	got := s.StrategizeTrace(ctx, app)
+	// Each voter simulates one action in full, but not a full search.
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("StrategizeTrace took %v, expected to stop soon after the deadline", d)
+	}
+	if len(got.Ballots) != len(s.Voters) {
+		t.Errorf("StrategizeTrace(done) => %d ballots, expected one from each of %d voters", len(got.Ballots), len(s.Voters))
+	}
+	checkMoves(t, "StrategizeTrace(done)", app, got.Chosen)
+}
+
+func TestStrategizeContextFallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a, _ := StrategizeContext(ctx, &RandomWalk{}, gui.NewAppState())
+	if a.Horizontal < -1 || a.Horizontal > 1 || a.Vertical < -1 || a.Vertical > 1 {
+		t.Errorf("StrategizeContext(RandomWalk) => %#v, expected a valid action", a)
+	}
+}
+
+func TestTraceJSON(t *testing.T) {
+	tr := Trace{
+		Chosen: toUp,
+		Rating: Rating{
+			Rate: 0.5,
+			Dist: 3,
+			Reason: &ChooseColorReason{&PaintReason{
+				NewColor: palettes.PICO8_RED,
+				OldColor: palettes.PICO8_BLACK,
+				Pos:      image.Point{X: 1, Y: 2},
+			}},
+		},
+		Ballots: []Ballot{{Voter: 1, Action: toUp, Rating: Rating{Rate: 0.5}}},
+	}
+
+	b, err := json.Marshal(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	want := `{"chosen":{"pressed":false,"horizontal":0,"vertical":-1},` +
+		`"rating":{"rate":0.5,"dist":3,"reason":"choose-color-painting with {255 0 77 255} over {0 0 0 255} @ (1,2)",` +
+		`"paint":{"new_color":"#ff004d","old_color":"#000000","pos":{"X":1,"Y":2}}},` +
+		`"ballots":[{"voter":1,"action":{"pressed":false,"horizontal":0,"vertical":-1},"rating":{"rate":0.5,"dist":0}}]}`
+	if got != want {
+		t.Errorf("json.Marshal(trace) =>\n\t%s\nexpected\n\t%s", got, want)
+	}
+}
+
+func TestIdealTraceCandidates(t *testing.T) {
+	app := newAppStatePinkBlock(3, 3)
+	app.Image.Set(3, 1, palettes.PICO8_BLACK)
+	s := &Ideal{Rating: perception.RateWholeImage}
+
+	got := s.StrategizeTrace(context.Background(), app)
+
+	if len(got.Candidates) != 2*len(directions) {
+		t.Fatalf("len(Candidates) => %d, expected %d", len(got.Candidates), 2*len(directions))
+	}
+	found := false
+	for _, c := range got.Candidates {
+		if c.Action == got.Chosen && c.Rating.Rate == got.Rating.Rate {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected chosen action %#v among candidates", got.Chosen)
+	}
+}
+
+func TestIdealRandTies(t *testing.T) {
+	// A constant rating ties every possible action.
+	rating := func(_ image.Image) float64 { return 0 }
+	var got [2][]gui.Action
+	for i := range got {
+		s := &Ideal{Rating: rating, Rand: rand.New(rand.NewSource(3))}
+		app := gui.NewAppState()
+		app.Cursor.Pos.X = gui.ImageX + 10
+		for j := 0; j < 5; j++ {
+			a, _ := s.Strategize(app)
+			got[i] = append(got[i], a)
+		}
+	}
+	if !reflect.DeepEqual(got[0], got[1]) {
+		t.Errorf("Ideal with the same Rand seed chose %v, then %v", got[0], got[1])
+	}
 }
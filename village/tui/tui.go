@@ -0,0 +1,82 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package tui draws images to a terminal with ANSI escape codes.
+//
+// Each character cell shows two pixels: the upper half block is drawn in the
+// foreground color of the top pixel on the background color of the bottom
+// pixel. This needs a terminal with 24-bit true color.
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+const (
+	// Clear clears the terminal.
+	Clear = "\x1b[2J"
+	// Home moves the terminal cursor to the top left, so that a new frame
+	// overwrites the last without flicker.
+	Home = "\x1b[H"
+	// HideCursor hides the terminal cursor while drawing.
+	HideCursor = "\x1b[?25l"
+	// ShowCursor shows the terminal cursor again.
+	ShowCursor = "\x1b[?25h"
+
+	reset     = "\x1b[0m"
+	clearLine = "\x1b[K"
+	halfBlock = "▀"
+)
+
+func rgb(c color.Color) color.RGBA {
+	r, g, b, _ := c.RGBA()
+	return color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 255}
+}
+
+// Draw writes an image as rows of half blocks.
+//
+// If the image has an odd height, the bottom half of the last row is left as
+// the terminal background.
+func Draw(w io.Writer, im image.Image) error {
+	bw := bufio.NewWriter(w)
+	b := im.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y += 2 {
+		var fg, bg color.RGBA
+		hasFg, hasBg := false, false
+		for x := b.Min.X; x < b.Max.X; x++ {
+			top := rgb(im.At(x, y))
+			if !hasFg || top != fg {
+				fmt.Fprintf(bw, "\x1b[38;2;%d;%d;%dm", top.R, top.G, top.B)
+				fg, hasFg = top, true
+			}
+			if y+1 < b.Max.Y {
+				bottom := rgb(im.At(x, y+1))
+				if !hasBg || bottom != bg {
+					fmt.Fprintf(bw, "\x1b[48;2;%d;%d;%dm", bottom.R, bottom.G, bottom.B)
+					bg, hasBg = bottom, true
+				}
+			}
+			bw.WriteString(halfBlock)
+		}
+		bw.WriteString(reset + clearLine + "\n")
+	}
+	return bw.Flush()
+}
+
+// Frame redraws the terminal with an image followed by lines of status.
+func Frame(w io.Writer, im image.Image, status ...string) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(Home)
+	if err := Draw(bw, im); err != nil {
+		return err
+	}
+	for _, s := range status {
+		bw.WriteString(s + clearLine + "\n")
+	}
+	return bw.Flush()
+}
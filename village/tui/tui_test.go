@@ -0,0 +1,50 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package tui
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+)
+
+func TestDraw(t *testing.T) {
+	im := image.NewPaletted(image.Rect(0, 0, 2, 3), palettes.PICO8)
+	im.Set(0, 0, palettes.PICO8_RED)
+	im.Set(1, 0, palettes.PICO8_RED)
+	im.Set(0, 2, palettes.PICO8_WHITE)
+
+	var b bytes.Buffer
+	if err := Draw(&b, im); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "\x1b[38;2;255;0;77m\x1b[48;2;0;0;0m▀▀" + reset + clearLine + "\n" +
+		"\x1b[38;2;255;241;232m▀\x1b[38;2;0;0;0m▀" + reset + clearLine + "\n"
+	if got := b.String(); got != want {
+		t.Errorf("Draw(im) => %q, expected %q", got, want)
+	}
+}
+
+func TestFrame(t *testing.T) {
+	im := image.NewPaletted(image.Rect(0, 0, 4, 4), palettes.PICO8)
+	var b bytes.Buffer
+	if err := Frame(&b, im, "frame: 1", "rating: 0.5"); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	if !strings.HasPrefix(got, Home) {
+		t.Errorf("Frame(...) => %q, expected to start with Home", got)
+	}
+	if !strings.HasSuffix(got, "frame: 1"+clearLine+"\nrating: 0.5"+clearLine+"\n") {
+		t.Errorf("Frame(...) => %q, expected to end with status lines", got)
+	}
+	if n := strings.Count(got, halfBlock); n != 8 {
+		t.Errorf("Frame(...) has %d half blocks, expected 8", n)
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package village runs several artists painting on a single shared canvas.
+package village
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"math/rand"
+	"os"
+
+	"github.com/tswast/pixelsketches/village/gui"
+	"github.com/tswast/pixelsketches/village/perception"
+	"github.com/tswast/pixelsketches/village/strategy"
+)
+
+// Struct Artist is a member of the village.
+type Artist struct {
+	Name        string
+	Strategizer strategy.Strategizer
+	// Rating is how the artist judges the shared canvas.
+	Rating perception.Rating
+	// App is the artist's view of the village. The cursor and selected color
+	// belong to the artist, but the image is shared with everyone.
+	App *gui.AppState
+	// Strokes counts the actions which changed the shared canvas.
+	Strokes int
+
+	turns int
+	pts   map[image.Point]int
+}
+
+// Scheduler decides which artists act during a frame.
+type Scheduler interface {
+	// Schedule advances the village by one frame.
+	Schedule(v *Village, frame int)
+}
+
+// Struct Village is a group of artists sharing one canvas.
+type Village struct {
+	Image     *image.Paletted
+	Artists   []*Artist
+	Scheduler Scheduler
+	// Owner is the index in Artists of the last artist to paint each pixel,
+	// in the same order as Image.Pix. Unpainted pixels are -1.
+	Owner []int
+}
+
+// New creates a village with a blank canvas and no artists.
+func New(sched Scheduler) *Village {
+	img := gui.NewAppState().Image
+	owner := make([]int, len(img.Pix))
+	for i := range owner {
+		owner[i] = -1
+	}
+	return &Village{Image: img, Scheduler: sched, Owner: owner}
+}
+
+// AddArtist adds an artist to the village.
+func (v *Village) AddArtist(name string, s strategy.Strategizer, r perception.Rating) *Artist {
+	app := gui.NewAppState()
+	app.Image = v.Image
+	a := &Artist{
+		Name:        name,
+		Strategizer: s,
+		Rating:      r,
+		App:         app,
+		pts:         make(map[image.Point]int),
+	}
+	v.Artists = append(v.Artists, a)
+	return a
+}
+
+// Done returns true when every artist has stopped drawing.
+func (v *Village) Done() bool {
+	for _, a := range v.Artists {
+		if a.App.Mode == gui.MODE_DRAWING {
+			return false
+		}
+	}
+	return true
+}
+
+// next returns the first artist still drawing, starting at index i.
+func (v *Village) next(i int) *Artist {
+	n := len(v.Artists)
+	for j := 0; j < n; j++ {
+		a := v.Artists[(i+j)%n]
+		if a.App.Mode == gui.MODE_DRAWING {
+			return a
+		}
+	}
+	return nil
+}
+
+func (v *Village) index(a *Artist) int {
+	for i, o := range v.Artists {
+		if o == a {
+			return i
+		}
+	}
+	return -1
+}
+
+// Strategize asks an artist to choose its next action.
+//
+// The artist stops drawing if it has wandered back to a position it visited
+// more than 20 turns ago, the same as a lone artist would.
+func (v *Village) Strategize(a *Artist) (gui.Action, strategy.Rating) {
+	act, r := a.Strategizer.Strategize(a.App)
+	a.turns++
+	dejavu, ok := a.pts[a.App.Cursor.Pos]
+	if !ok {
+		a.pts[a.App.Cursor.Pos] = a.turns
+	} else if a.turns-dejavu > 20 {
+		log.Printf("%s already been at this position", a.Name)
+		a.App.Mode = gui.MODE_DONE
+	}
+	return act, r
+}
+
+// target returns the cursor position after an action is applied.
+func target(pos image.Point, act gui.Action) image.Point {
+	pt := image.Point{X: pos.X + act.Horizontal, Y: pos.Y + act.Vertical}
+	if pt.X < 0 {
+		pt.X = 0
+	}
+	if pt.X >= gui.ScreenWidth {
+		pt.X = gui.ScreenWidth - 1
+	}
+	if pt.Y < 0 {
+		pt.Y = 0
+	}
+	if pt.Y >= gui.ScreenHeight {
+		pt.Y = gui.ScreenHeight - 1
+	}
+	return pt
+}
+
+// Apply applies an artist's action to the shared canvas.
+//
+// Claimed pixels were already changed by another artist during this frame. If
+// the action would paint over one of them, the other artist keeps the pixel.
+// Pass a nil map when artists can't conflict.
+func (v *Village) Apply(a *Artist, act gui.Action, claimed map[image.Point]*Artist) {
+	tgt := target(a.App.Cursor.Pos, act)
+	pt := image.Point{X: tgt.X - gui.ImageX, Y: tgt.Y}
+	onCanvas := pt.In(v.Image.Bounds())
+	var old uint8
+	if onCanvas {
+		old = v.Image.ColorIndexAt(pt.X, pt.Y)
+	}
+	a.App.ApplyAction(&act)
+	if !onCanvas || v.Image.ColorIndexAt(pt.X, pt.Y) == old {
+		return
+	}
+	if w, ok := claimed[pt]; ok && w != a {
+		v.Image.SetColorIndex(pt.X, pt.Y, old)
+		return
+	}
+	if claimed != nil {
+		claimed[pt] = a
+	}
+	v.Owner[v.Image.PixOffset(pt.X, pt.Y)] = v.index(a)
+	a.Strokes++
+}
+
+// Step advances the village by one frame.
+func (v *Village) Step(frame int) {
+	v.Scheduler.Schedule(v, frame)
+}
+
+// Contributions counts the pixels currently owned by each artist.
+//
+// The counts are in the same order as Artists.
+func (v *Village) Contributions() []int {
+	cnts := make([]int, len(v.Artists))
+	for _, o := range v.Owner {
+		if o >= 0 {
+			cnts[o]++
+		}
+	}
+	return cnts
+}
+
+// DrawScreen draws the user interface with every artist's cursor.
+//
+// The selected color shown is that of the first artist.
+func (v *Village) DrawScreen() *image.NRGBA {
+	if len(v.Artists) == 0 {
+		app := gui.NewAppState()
+		app.Image = v.Image
+		return app.DrawScreen()
+	}
+	scr := v.Artists[0].App.DrawScreen()
+	for _, a := range v.Artists[1:] {
+		gui.DrawCursor(scr, a.App.Cursor)
+	}
+	return scr
+}
+
+// RoundRobin lets one artist act per frame, taking turns in order.
+type RoundRobin struct{}
+
+func (_ *RoundRobin) Schedule(v *Village, frame int) {
+	if len(v.Artists) == 0 {
+		return
+	}
+	a := v.next(frame % len(v.Artists))
+	if a == nil {
+		return
+	}
+	act, _ := v.Strategize(a)
+	v.Apply(a, act, nil)
+}
+
+// TimeSliced lets one artist act for Slice frames in a row before the next
+// artist takes over.
+type TimeSliced struct {
+	Slice int
+}
+
+func (s *TimeSliced) Schedule(v *Village, frame int) {
+	if len(v.Artists) == 0 {
+		return
+	}
+	slice := s.Slice
+	if slice < 1 {
+		slice = 1
+	}
+	a := v.next((frame / slice) % len(v.Artists))
+	if a == nil {
+		return
+	}
+	act, _ := v.Strategize(a)
+	v.Apply(a, act, nil)
+}
+
+// Simultaneous lets every artist act each frame.
+//
+// All artists choose their actions from the same canvas. The actions are then
+// applied in a random order, and when two artists paint the same pixel the
+// first one wins.
+type Simultaneous struct{}
+
+func (_ *Simultaneous) Schedule(v *Village, frame int) {
+	acts := make([]gui.Action, len(v.Artists))
+	active := make([]bool, len(v.Artists))
+	for i, a := range v.Artists {
+		if a.App.Mode != gui.MODE_DRAWING {
+			continue
+		}
+		acts[i], _ = v.Strategize(a)
+		active[i] = true
+	}
+	claimed := make(map[image.Point]*Artist)
+	for _, i := range rand.Perm(len(v.Artists)) {
+		if !active[i] {
+			continue
+		}
+		v.Apply(v.Artists[i], acts[i], claimed)
+	}
+}
+
+func tryWriteFrame(frame int, v *Village) {
+	scr := v.DrawScreen()
+	// Write timeline image if we can.
+	f, err := os.Create(fmt.Sprintf("out/out-%04d.png", frame))
+	if err != nil {
+		log.Printf("Could not create out/out-%04d.png %s\n", frame, err)
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := png.Encode(w, scr); err != nil {
+		log.Printf("Could not encode out/out-%04d.png %s\n", frame, err)
+	}
+	w.Flush()
+}
+
+// Run lets the village draw until every artist is done or maxIter frames
+// have passed, and returns the number of frames.
+//
+// If frameFn is not nil, it is called before each frame.
+func (v *Village) Run(maxIter int, frameFn func(frame int)) int {
+	frame := 0
+	for ; ; frame++ {
+		if frame > maxIter {
+			log.Printf("reached max iterations %d\n", maxIter)
+			break
+		}
+		if v.Done() {
+			break
+		}
+		if frameFn != nil {
+			frameFn(frame)
+		}
+		v.Step(frame)
+	}
+	return frame
+}
+
+// Main lets the village draw a picture, writes it, and exits.
+func Main(outPath string, seed int64, doTimeLapse bool, maxIter int, v *Village) error {
+	rand.Seed(seed)
+
+	frame := v.Run(maxIter, func(frame int) {
+		if doTimeLapse {
+			tryWriteFrame(frame, v)
+		}
+		if frame%100 == 0 {
+			log.Printf("current-frame: %d\n", frame)
+		}
+	})
+	fmt.Printf("frames: %d\n", frame)
+	cnts := v.Contributions()
+	for i, a := range v.Artists {
+		rt := 0.0
+		if a.Rating != nil {
+			rt = a.Rating(v.Image)
+		}
+		fmt.Printf("%s: pixels: %d strokes: %d rating: %f\n", a.Name, cnts[i], a.Strokes, rt)
+	}
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %s", outPath, err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err := png.Encode(w, v.Image); err != nil {
+		return fmt.Errorf("Error encoding %s: %s", outPath, err)
+	}
+	w.Flush()
+	return nil
+}
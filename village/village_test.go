@@ -0,0 +1,142 @@
+// Copyright 2016 Google Inc.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package village
+
+import (
+	"image"
+	"testing"
+
+	"github.com/tswast/pixelsketches/palettes"
+	"github.com/tswast/pixelsketches/village/gui"
+	"github.com/tswast/pixelsketches/village/strategy"
+)
+
+// scripted always chooses the same action.
+type scripted struct {
+	act gui.Action
+}
+
+func (s *scripted) Strategize(_ *gui.AppState) (gui.Action, strategy.Rating) {
+	return s.act, strategy.Rating{}
+}
+
+func newPainter(v *Village, name string, pos image.Point) *Artist {
+	a := v.AddArtist(name, &scripted{gui.Action{Pressed: true}}, nil)
+	a.App.Cursor.Pos = pos
+	return a
+}
+
+func TestAddArtistSharesCanvas(t *testing.T) {
+	v := New(&RoundRobin{})
+	a := v.AddArtist("a", &strategy.RandomWalk{}, nil)
+	b := v.AddArtist("b", &strategy.RandomWalk{}, nil)
+	a.App.Image.Set(3, 4, palettes.PICO8_PINK)
+	if got := b.App.Image.At(3, 4); got != palettes.PICO8_PINK {
+		t.Errorf("b.App.Image.At(3, 4) => %v, expected %v", got, palettes.PICO8_PINK)
+	}
+	a.App.Cursor.Pos.X = 10
+	if b.App.Cursor.Pos.X == 10 {
+		t.Error("Expected artists to have separate cursors.")
+	}
+}
+
+var schedtests = []struct {
+	name  string
+	sched Scheduler
+	// Index of the artist expected to act in each frame.
+	turns []int
+}{
+	{"round-robin", &RoundRobin{}, []int{0, 1, 2, 0, 1, 2}},
+	{"time-sliced", &TimeSliced{Slice: 2}, []int{0, 0, 1, 1, 2, 2}},
+}
+
+func TestSchedulerTurns(t *testing.T) {
+	for _, tt := range schedtests {
+		v := New(tt.sched)
+		for i := 0; i < 3; i++ {
+			a := newPainter(v, "painter", image.Point{X: gui.ImageX + 10*i, Y: 10})
+			a.App.Color = palettes.PICO8[i+1]
+		}
+		for frame, want := range tt.turns {
+			before := make([]int, len(v.Artists))
+			for i, a := range v.Artists {
+				before[i] = a.turns
+			}
+			v.Step(frame)
+			for i, a := range v.Artists {
+				acted := a.turns != before[i]
+				if acted != (i == want) {
+					t.Errorf("%s: frame %d, artist %d acted => %v, expected %v", tt.name, frame, i, acted, i == want)
+				}
+			}
+		}
+	}
+}
+
+func TestContributions(t *testing.T) {
+	v := New(&Simultaneous{})
+	a := newPainter(v, "a", image.Point{X: gui.ImageX + 1, Y: 1})
+	a.App.Color = palettes.PICO8_PINK
+	b := newPainter(v, "b", image.Point{X: gui.ImageX + 5, Y: 5})
+	b.App.Color = palettes.PICO8_GREEN
+	c := v.AddArtist("c", &scripted{gui.Action{Horizontal: -1}}, nil)
+	c.App.Cursor.Pos = image.Point{X: 5, Y: 5}
+
+	v.Step(0)
+
+	got := v.Contributions()
+	want := []int{1, 1, 0}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Contributions()[%d] => %d, expected %d", i, got[i], want[i])
+		}
+	}
+	if v.Image.At(1, 1) != palettes.PICO8_PINK {
+		t.Errorf("v.Image.At(1, 1) => %v, expected %v", v.Image.At(1, 1), palettes.PICO8_PINK)
+	}
+	if b.Strokes != 1 {
+		t.Errorf("b.Strokes => %d, expected 1", b.Strokes)
+	}
+}
+
+func TestSimultaneousConflict(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		v := New(&Simultaneous{})
+		a := newPainter(v, "a", image.Point{X: gui.ImageX + 1, Y: 1})
+		a.App.Color = palettes.PICO8_PINK
+		b := newPainter(v, "b", image.Point{X: gui.ImageX + 1, Y: 1})
+		b.App.Color = palettes.PICO8_GREEN
+
+		v.Step(i)
+
+		if a.Strokes+b.Strokes != 1 {
+			t.Fatalf("Expected exactly one stroke, got a: %d, b: %d", a.Strokes, b.Strokes)
+		}
+		w := a
+		if b.Strokes == 1 {
+			w = b
+		}
+		if got := v.Image.At(1, 1); got != w.App.Color {
+			t.Errorf("v.Image.At(1, 1) => %v, expected winner's color %v", got, w.App.Color)
+		}
+		if got := v.Owner[v.Image.PixOffset(1, 1)]; got != v.index(w) {
+			t.Errorf("v.Owner at (1, 1) => %d, expected %d", got, v.index(w))
+		}
+	}
+}
+
+func TestDrawScreenShowsEveryCursor(t *testing.T) {
+	v := New(&RoundRobin{})
+	v.AddArtist("a", &strategy.RandomWalk{}, nil).App.Cursor.Pos = image.Point{X: gui.ImageX + 2, Y: 2}
+	v.AddArtist("b", &strategy.RandomWalk{}, nil).App.Cursor.Pos = image.Point{X: gui.ImageX + 40, Y: 30}
+	scr := v.DrawScreen()
+	for _, a := range v.Artists {
+		pos := a.App.Cursor.Pos
+		r, g, b, _ := scr.At(pos.X, pos.Y).RGBA()
+		if r == 0 && g == 0 && b == 0 {
+			t.Errorf("Expected cursor for %s at %v, got black", a.Name, pos)
+		}
+	}
+}